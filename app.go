@@ -16,15 +16,22 @@ limitations under the License.
 package main
 
 import (
+	"SLALite/assessment"
+	amodel "SLALite/assessment/model"
+	"SLALite/assessment/monitor/genericadapter"
 	"SLALite/generator"
 	"SLALite/model"
 	"SLALite/utils"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/gorilla/mux"
@@ -37,10 +44,22 @@ const (
 	defaultSslCertPath string = "cert.pem"
 	defaultSslKeyPath  string = "key.pem"
 
-	portPropertyName        = "port"
-	enableSslPropertyName   = "enableSsl"
-	sslCertPathPropertyName = "sslCertPath"
-	sslKeyPathPropertyName  = "sslKeyPath"
+	// defaultMaxReassessInterval is the default maximum number of seconds of the
+	// interval of a re-assessment (30 days)
+	defaultMaxReassessInterval time.Duration = 30 * 24 * 3600
+	// defaultMaxReassessSteps is the default maximum number of assessments of a
+	// re-assessment (enough for 30 days with the default check period)
+	defaultMaxReassessSteps int = 50000
+
+	// reassessmentRetention is how long a finished re-assessment is kept
+	reassessmentRetention = 24 * time.Hour
+
+	portPropertyName                = "port"
+	enableSslPropertyName           = "enableSsl"
+	sslCertPathPropertyName         = "sslCertPath"
+	sslKeyPathPropertyName          = "sslKeyPath"
+	maxReassessIntervalPropertyName = "maxReassessInterval"
+	maxReassessStepsPropertyName    = "maxReassessSteps"
 )
 
 // App is a main application "object", to be built by main and testmain
@@ -54,6 +73,14 @@ type App struct {
 	SslKeyPath  string
 	externalIDs bool
 	validator   model.Validator
+	// scheduler runs the re-assessments, so that they are not run while the
+	// periodic assessment assesses the same agreement
+	scheduler *assessment.Scheduler
+	// maxReassess is the maximum length of the interval of a re-assessment
+	maxReassess time.Duration
+	// maxReassessSteps is the maximum number of assessments of a re-assessment
+	maxReassessSteps int
+	reassessments    *reassessments
 }
 
 // ApiError is the struct sent to client on errors
@@ -80,23 +107,30 @@ type endpoint struct {
 }
 
 var api = map[string]endpoint{
-	"providers":  endpoint{"GET", "/providers", "Providers"},
-	"agreements": endpoint{"GET", "/agreements", "Agreements"},
-	"templates":  endpoint{"GET", "/templates", "Templates"},
+	"providers":           endpoint{"GET", "/providers", "Providers"},
+	"agreements":          endpoint{"GET", "/agreements", "Agreements"},
+	"templates":           endpoint{"GET", "/templates", "Templates"},
+	"violations":          endpoint{"GET", "/violations", "Violations"},
+	"maintenance-windows": endpoint{"GET", "/maintenance-windows", "Maintenance windows"},
 }
 
-func NewApp(config *viper.Viper, repository model.IRepository, validator model.Validator) (App, error) {
+func NewApp(config *viper.Viper, repository model.IRepository, validator model.Validator,
+	scheduler *assessment.Scheduler) (App, error) {
 
 	setDefaults(config)
 	logConfig(config)
 
 	a := App{
-		Port:        config.GetString(portPropertyName),
-		SslEnabled:  config.GetBool(enableSslPropertyName),
-		SslCertPath: config.GetString(sslCertPathPropertyName),
-		SslKeyPath:  config.GetString(sslKeyPathPropertyName),
-		externalIDs: config.GetBool(utils.ExternalIDsPropertyName),
-		validator:   validator,
+		Port:             config.GetString(portPropertyName),
+		SslEnabled:       config.GetBool(enableSslPropertyName),
+		SslCertPath:      config.GetString(sslCertPathPropertyName),
+		SslKeyPath:       config.GetString(sslKeyPathPropertyName),
+		externalIDs:      config.GetBool(utils.ExternalIDsPropertyName),
+		validator:        validator,
+		scheduler:        scheduler,
+		maxReassess:      config.GetDuration(maxReassessIntervalPropertyName) * time.Second,
+		maxReassessSteps: config.GetInt(maxReassessStepsPropertyName),
+		reassessments:    newReassessments(),
 	}
 
 	a.initialize(repository)
@@ -110,6 +144,9 @@ func setDefaults(config *viper.Viper) {
 	config.SetDefault(portPropertyName, defaultPort)
 	config.SetDefault(sslCertPathPropertyName, defaultSslCertPath)
 	config.SetDefault(sslKeyPathPropertyName, defaultSslKeyPath)
+	config.SetDefault(utils.CheckPeriodPropertyName, utils.DefaultCheckPeriod)
+	config.SetDefault(maxReassessIntervalPropertyName, defaultMaxReassessInterval)
+	config.SetDefault(maxReassessStepsPropertyName, defaultMaxReassessSteps)
 }
 
 func logConfig(config *viper.Viper) {
@@ -152,6 +189,17 @@ func (a *App) initialize(repository model.IRepository) {
 	a.Router.Methods("PUT").Path("/agreements/{id}").Handler(logger(a.UpdateAgreement))
 	a.Router.Methods("DELETE").Path("/agreements/{id}").Handler(logger(a.DeleteAgreement))
 	a.Router.Methods("GET").Path("/agreements/{id}/details").Handler(logger(a.GetAgreementDetails))
+	a.Router.Methods("GET").Path("/agreements/{id}/violations").Handler(logger(a.GetAgreementViolations))
+	a.Router.Methods("GET").Path("/agreements/{id}/penalties").Handler(logger(a.GetAgreementPenalties))
+	a.Router.Methods("GET").Path("/agreements/{id}/incidents").Handler(logger(a.GetAgreementIncidents))
+	a.Router.Methods("GET").Path("/agreements/{id}/compliance").Handler(logger(a.GetAgreementCompliance))
+	a.Router.Methods("GET").Path("/agreements/{id}/errors").Handler(logger(a.GetAgreementErrors))
+	a.Router.Methods("POST").Path("/agreements/{id}/evaluate").Handler(logger(a.EvaluateAgreement))
+	a.Router.Methods("POST").Path("/agreements/{id}/reassess").Handler(logger(a.ReassessAgreement))
+
+	a.Router.Methods("GET").Path("/reassessments/{id}").Handler(logger(a.GetReassessment))
+
+	a.Router.Methods("POST").Path("/evaluate").Handler(logger(a.Evaluate))
 
 	a.Router.Methods("GET").Path("/templates").Handler(logger(a.GetTemplates))
 	a.Router.Methods("GET").Path("/templates/{id}").Handler(logger(a.GetTemplate))
@@ -159,6 +207,15 @@ func (a *App) initialize(repository model.IRepository) {
 
 	a.Router.Methods("POST").Path("/create-agreement").Handler(logger(a.CreateAgreementFromTemplate))
 
+	a.Router.Methods("GET").Path("/violations").Handler(logger(a.GetViolations))
+	a.Router.Methods("GET").Path("/violations/{id}").Handler(logger(a.GetViolation))
+
+	a.Router.Methods("GET").Path("/maintenance-windows").Handler(logger(a.GetMaintenanceWindows))
+	a.Router.Methods("GET").Path("/maintenance-windows/{id}").Handler(logger(a.GetMaintenanceWindow))
+	a.Router.Methods("POST").Path("/maintenance-windows").Handler(logger(a.CreateMaintenanceWindow))
+	a.Router.Methods("PUT").Path("/maintenance-windows/{id}").Handler(logger(a.UpdateMaintenanceWindow))
+	a.Router.Methods("DELETE").Path("/maintenance-windows/{id}").Handler(logger(a.DeleteMaintenanceWindow))
+
 }
 
 // Run starts the REST API
@@ -396,6 +453,16 @@ func (a *App) DeleteProvider(w http.ResponseWriter, r *http.Request) {
 // ---
 // produces:
 // - application/json
+// parameters:
+// - name: active
+//   in: query
+//   description: If set, only the started agreements are returned
+//   type: string
+// - name: status
+//   in: query
+//   description: If set, only the agreements whose assessment has this status are returned
+//   type: string
+//   enum: [fulfilled, warning, violated, unknown]
 // responses:
 //   '200':
 //     description: The complete list of registered agreements
@@ -403,15 +470,35 @@ func (a *App) DeleteProvider(w http.ResponseWriter, r *http.Request) {
 //       type: object
 //       additionalProperties:
 //         "$ref": "#/definitions/Agreements"
+//   '400' :
+//     description: Wrong query parameters
 func (a *App) GetAgreements(w http.ResponseWriter, r *http.Request) {
 	v := r.URL.Query()
 	active := v.Get("active")
+	status := model.Status(v.Get("status"))
+	if status != "" && !status.IsValid() {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid status parameter: %s", status))
+		return
+	}
 
 	a.getAll(w, r, func() (interface{}, error) {
+		var agreements model.Agreements
+		var err error
 		if active != "" {
-			return a.Repository.GetAgreementsByState(model.STARTED)
+			agreements, err = a.Repository.GetAgreementsByState(model.STARTED)
+		} else {
+			agreements, err = a.Repository.GetAllAgreements()
+		}
+		if err != nil || status == "" {
+			return agreements, err
 		}
-		return a.Repository.GetAllAgreements()
+		result := make(model.Agreements, 0, len(agreements))
+		for _, agreement := range agreements {
+			if agreement.Assessment.Status == status {
+				result = append(result, agreement)
+			}
+		}
+		return result, nil
 	})
 }
 
@@ -749,6 +836,937 @@ func (a *App) CreateAgreementFromTemplate(w http.ResponseWriter, r *http.Request
 		})
 }
 
+// GetViolations return the violations in db that match the query filters
+// swagger:operation GET /violations getViolations
+//
+// Returns the violations that match the filters passed as query parameters
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: guarantee
+//   in: query
+//   description: Name of the violated guarantee term
+//   type: string
+// - name: provider
+//   in: query
+//   description: Identifier of the provider of the violated agreement
+//   type: string
+// - name: client
+//   in: query
+//   description: Identifier of the client of the violated agreement
+//   type: string
+// - name: from
+//   in: query
+//   description: Violations raised at this time (RFC3339) or later
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: Violations raised before this time (RFC3339)
+//   type: string
+//   format: date-time
+// responses:
+//   '200':
+//     description: The list of violations that match the filters
+//     schema:
+//       "$ref": "#/definitions/Violations"
+//   '400' :
+//     description: Wrong query parameters
+func (a *App) GetViolations(w http.ResponseWriter, r *http.Request) {
+	q, err := parseViolationQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.getAll(w, r, func() (interface{}, error) {
+		return a.Repository.GetViolations(q)
+	})
+}
+
+// GetViolation gets a violation by REST ID
+// swagger:operation GET /violations/{id} getViolation
+//
+// Returns a violation given its ID
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the violation
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The violation with the ID
+//     schema:
+//       "$ref": "#/definitions/Violation"
+//   '404' :
+//     description: Violation not found
+func (a *App) GetViolation(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		return a.Repository.GetViolation(id)
+	})
+}
+
+// GetMaintenanceWindows return the maintenance windows in db that match the query filters
+// swagger:operation GET /maintenance-windows getMaintenanceWindows
+//
+// Returns the maintenance windows that match the filters passed as query parameters
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: agreement
+//   in: query
+//   description: Identifier of the agreement of the windows
+//   type: string
+// - name: provider
+//   in: query
+//   description: Identifier of the provider of the windows
+//   type: string
+// responses:
+//   '200':
+//     description: The list of maintenance windows that match the filters
+//     schema:
+//       "$ref": "#/definitions/MaintenanceWindows"
+func (a *App) GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	q := model.MaintenanceWindowQuery{
+		AgreementId: r.URL.Query().Get("agreement"),
+		ProviderId:  r.URL.Query().Get("provider"),
+	}
+	a.getAll(w, r, func() (interface{}, error) {
+		return a.Repository.GetMaintenanceWindows(q)
+	})
+}
+
+// GetMaintenanceWindow gets a maintenance window by REST ID
+// swagger:operation GET /maintenance-windows/{id} getMaintenanceWindow
+//
+// Returns a maintenance window given its ID
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the maintenance window
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The maintenance window with the ID
+//     schema:
+//       "$ref": "#/definitions/MaintenanceWindow"
+//   '404' :
+//     description: Maintenance window not found
+func (a *App) GetMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		return a.Repository.GetMaintenanceWindow(id)
+	})
+}
+
+// CreateMaintenanceWindow creates a maintenance window passed by REST params
+// swagger:operation POST /maintenance-windows createMaintenanceWindow
+//
+// Creates a maintenance window with the information passed in the request body
+//
+// ---
+// produces:
+// - application/json
+// consumes:
+// - application/json
+// parameters:
+// - name: window
+//   in: body
+//   description: The maintenance window to create
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/MaintenanceWindow"
+// responses:
+//   '201':
+//     description: The new maintenance window that has been created
+//     schema:
+//       "$ref": "#/definitions/MaintenanceWindow"
+//   '400' :
+//     description: Not valid maintenance window
+func (a *App) CreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var window model.MaintenanceWindow
+	a.create(w, r,
+		func() error {
+			return json.NewDecoder(r.Body).Decode(&window)
+		},
+		func() (model.Identity, error) {
+			return a.Repository.CreateMaintenanceWindow(&window)
+		})
+}
+
+// UpdateMaintenanceWindow replaces a maintenance window with the one in the body.
+// The Id in the body is ignored; only the id path is taken into account.
+// swagger:operation PUT /maintenance-windows/{id} updateMaintenanceWindow
+//
+// Updates the maintenance window whose ID is passed as parameter
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the maintenance window
+//   required: true
+//   type: string
+// - name: window
+//   in: body
+//   description: The information to update
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/MaintenanceWindow"
+// responses:
+//   '200':
+//     description: The updated maintenance window
+//     schema:
+//       "$ref": "#/definitions/MaintenanceWindow"
+//   '400' :
+//     description: Not valid maintenance window
+//   '404' :
+//     description: Maintenance window not found
+func (a *App) UpdateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	var window model.MaintenanceWindow
+
+	a.updateEntity(w, r,
+		func() error {
+			return json.NewDecoder(r.Body).Decode(&window)
+		},
+		func(id string) (model.Identity, error) {
+			window.Id = id
+			return a.Repository.UpdateMaintenanceWindow(&window)
+		})
+}
+
+// DeleteMaintenanceWindow deletes /maintenance-windows/id
+// swagger:operation DELETE /maintenance-windows/{id} deleteMaintenanceWindow
+//
+// Deletes a maintenance window given its ID
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the maintenance window
+//   required: true
+//   type: string
+// responses:
+//   '204':
+//     description: The maintenance window has been successfully deleted
+//   '404' :
+//     description: Maintenance window not found
+func (a *App) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	a.update(w, r, func(id string) error {
+		return a.Repository.DeleteMaintenanceWindow(&model.MaintenanceWindow{Id: id})
+	})
+}
+
+// GetAgreementViolations return the violations of an agreement
+// swagger:operation GET /agreements/{id}/violations getAgreementViolations
+//
+// Returns the violations of the agreement whose ID is passed as parameter.
+// The same query filters of /violations can be applied.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The list of violations of the agreement
+//     schema:
+//       "$ref": "#/definitions/Violations"
+//   '400' :
+//     description: Wrong query parameters
+//   '404' :
+//     description: Agreement not found
+func (a *App) GetAgreementViolations(w http.ResponseWriter, r *http.Request) {
+	q, err := parseViolationQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.get(w, r, func(id string) (interface{}, error) {
+		if _, err := a.Repository.GetAgreement(id); err != nil {
+			return nil, err
+		}
+		q.AgreementId = id
+		return a.Repository.GetViolations(q)
+	})
+}
+
+// GetAgreementPenalties return the penalties of an agreement
+// swagger:operation GET /agreements/{id}/penalties getAgreementPenalties
+//
+// Returns the penalties raised by the violations of the agreement whose ID
+// is passed as parameter.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: guarantee
+//   in: query
+//   description: Name of the violated guarantee term
+//   type: string
+// - name: violation
+//   in: query
+//   description: Identifier of the violation that raised the penalty
+//   type: string
+// - name: from
+//   in: query
+//   description: Penalties raised at this time (RFC3339) or later
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: Penalties raised before this time (RFC3339)
+//   type: string
+//   format: date-time
+// responses:
+//   '200':
+//     description: The list of penalties of the agreement
+//     schema:
+//       "$ref": "#/definitions/Penalties"
+//   '400' :
+//     description: Wrong query parameters
+//   '404' :
+//     description: Agreement not found
+func (a *App) GetAgreementPenalties(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	q := model.PenaltyQuery{
+		Guarantee:   v.Get("guarantee"),
+		ViolationId: v.Get("violation"),
+	}
+	var err error
+	if q.From, err = parseTimeParam(v.Get("from"), "from"); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.To, err = parseTimeParam(v.Get("to"), "to"); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.get(w, r, func(id string) (interface{}, error) {
+		if _, err := a.Repository.GetAgreement(id); err != nil {
+			return nil, err
+		}
+		q.AgreementId = id
+		return a.Repository.GetPenalties(q)
+	})
+}
+
+// GetAgreementIncidents return the incidents of an agreement
+// swagger:operation GET /agreements/{id}/incidents getAgreementIncidents
+//
+// Returns the incidents (periods of consecutive violations of a guarantee term)
+// of the agreement whose ID is passed as parameter.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: guarantee
+//   in: query
+//   description: Name of the violated guarantee term
+//   type: string
+// - name: open
+//   in: query
+//   description: If true, only the incidents that are still open are returned
+//   type: boolean
+// - name: from
+//   in: query
+//   description: Incidents started at this time (RFC3339) or later
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: Incidents started before this time (RFC3339)
+//   type: string
+//   format: date-time
+// responses:
+//   '200':
+//     description: The list of incidents of the agreement
+//     schema:
+//       "$ref": "#/definitions/Incidents"
+//   '400' :
+//     description: Wrong query parameters
+//   '404' :
+//     description: Agreement not found
+func (a *App) GetAgreementIncidents(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	q := model.IncidentQuery{
+		Guarantee: v.Get("guarantee"),
+	}
+	var err error
+	if open := v.Get("open"); open != "" {
+		if q.OnlyOpen, err = strconv.ParseBool(open); err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid open parameter: %s", open))
+			return
+		}
+	}
+	if q.From, err = parseTimeParam(v.Get("from"), "from"); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.To, err = parseTimeParam(v.Get("to"), "to"); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.get(w, r, func(id string) (interface{}, error) {
+		if _, err := a.Repository.GetAgreement(id); err != nil {
+			return nil, err
+		}
+		q.AgreementId = id
+		return a.Repository.GetIncidents(q)
+	})
+}
+
+// GetAgreementCompliance return the compliance of the guarantee terms of an agreement
+// swagger:operation GET /agreements/{id}/compliance getAgreementCompliance
+//
+// Returns, for each guarantee term of the agreement whose ID is passed as
+// parameter, the fraction of evaluated points and time that fulfilled the
+// term and the remaining error budget in a compliance period.
+// Terms not evaluated in the period are not included. The attainment of terms
+// without an objective is returned too, without objective nor error budget.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: period
+//   in: query
+//   description: The compliance period; one of current (default) or previous
+//   type: string
+//   enum: [current, previous]
+// responses:
+//   '200':
+//     description: The compliance of each guarantee term, by name
+//     schema:
+//       type: object
+//       additionalProperties:
+//         "$ref": "#/definitions/CompliancePeriod"
+//   '400' :
+//     description: Wrong query parameters
+//   '404' :
+//     description: Agreement not found
+func (a *App) GetAgreementCompliance(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "current"
+	}
+	if period != "current" && period != "previous" {
+		respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid period parameter: %s. Valid values are current and previous", period))
+		return
+	}
+	a.get(w, r, func(id string) (interface{}, error) {
+		agreement, err := a.Repository.GetAgreement(id)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]model.CompliancePeriod)
+		for name, ag := range agreement.Assessment.Guarantees {
+			if ag.Compliance == nil {
+				continue
+			}
+			p := ag.Compliance.Current
+			if period == "previous" {
+				p = ag.Compliance.Previous
+			}
+			if p != nil {
+				result[name] = *p
+			}
+		}
+		return result, nil
+	})
+}
+
+// GetAgreementErrors return the errors of the guarantee terms of an agreement
+// swagger:operation GET /agreements/{id}/errors getAgreementErrors
+//
+// Returns, for each guarantee term of the agreement whose ID is passed as
+// parameter that could not be evaluated in its last assessment, the error
+// message and the time of the assessment.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The error of each failing guarantee term, by name
+//     schema:
+//       type: object
+//       additionalProperties:
+//         "$ref": "#/definitions/EvaluationError"
+//   '404' :
+//     description: Agreement not found
+func (a *App) GetAgreementErrors(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		agreement, err := a.Repository.GetAgreement(id)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]model.EvaluationError)
+		for name, ag := range agreement.Assessment.Guarantees {
+			if ag.Error != nil {
+				result[name] = *ag.Error
+			}
+		}
+		return result, nil
+	})
+}
+
+// Evaluate evaluates an agreement against the metrics in the request
+// swagger:operation POST /evaluate evaluate
+//
+// Evaluates an agreement against the series of metric values passed in the body,
+// returning the violations, warnings and compliance that the agreement
+// would have. The agreement is either passed in the body or, if agreement_id
+// is set, it is the stored agreement with that ID. The evaluation starts from
+// an empty assessment, and nothing is persisted nor notified.
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// - name: evaluation
+//   in: body
+//   description: The agreement and the metric values to evaluate
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/Evaluation"
+// responses:
+//   '200':
+//     description: The result of the evaluation
+//     schema:
+//       "$ref": "#/definitions/EvaluationResult"
+//   '400' :
+//     description: Wrong body, non numeric metric values or invalid agreement
+//   '404' :
+//     description: Agreement not found
+func (a *App) Evaluate(w http.ResponseWriter, r *http.Request) {
+	var in model.Evaluation
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkEvaluationMetrics(in.Metrics); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (in.Agreement == nil) == (in.AgreementID == "") {
+		respondWithError(w, http.StatusBadRequest, "Exactly one of agreement or agreement_id must be set")
+		return
+	}
+	agreement := in.Agreement
+	if agreement != nil {
+		if errs := agreement.Validate(a.validator, model.UPDATE); len(errs) > 0 {
+			respondWithError(w, http.StatusBadRequest, validationMessage(errs))
+			return
+		}
+	} else {
+		var err error
+		if agreement, err = a.Repository.GetAgreement(in.AgreementID); err != nil {
+			manageError(err, w)
+			return
+		}
+	}
+	result, err := evaluate(r.Context(), *agreement, in)
+	if err != nil {
+		manageError(err, w)
+		return
+	}
+	respondSuccessJSON(w, result)
+}
+
+// EvaluateAgreement evaluates a stored agreement against the metrics in the request
+// swagger:operation POST /agreements/{id}/evaluate evaluateAgreement
+//
+// Evaluates the agreement whose ID is passed as parameter against the series of
+// metric values passed in the body, returning the violations, warnings and
+// compliance that the agreement would have. The agreement fields of the body
+// are ignored. The evaluation starts from an empty assessment, and nothing is
+// persisted nor notified.
+//
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: evaluation
+//   in: body
+//   description: The metric values to evaluate
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/Evaluation"
+// responses:
+//   '200':
+//     description: The result of the evaluation
+//     schema:
+//       "$ref": "#/definitions/EvaluationResult"
+//   '400' :
+//     description: Wrong body or non numeric metric values
+//   '404' :
+//     description: Agreement not found
+func (a *App) EvaluateAgreement(w http.ResponseWriter, r *http.Request) {
+	var in model.Evaluation
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := checkEvaluationMetrics(in.Metrics); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.get(w, r, func(id string) (interface{}, error) {
+		agreement, err := a.Repository.GetAgreement(id)
+		if err != nil {
+			return nil, err
+		}
+		return evaluate(r.Context(), *agreement, in)
+	})
+}
+
+// ReassessAgreement starts the re-assessment of an agreement over a past interval
+// swagger:operation POST /agreements/{id}/reassess reassessAgreement
+//
+// Starts a job that re-runs the assessment of the agreement whose ID is passed as
+// parameter over a past interval, stepping through it with the schedule of the
+// agreement or, if not set, the check period, as if the periodic assessment had
+// run then. The raised violations are stored marked as backfilled, and they are not
+// notified; the violations that were already stored are not raised again. The
+// assessment state of the agreement is not modified. The interval may not be
+// longer than the maxReassessInterval setting, nor take more assessments than the
+// maxReassessSteps setting.
+//
+// The job runs in background; its status is returned at the location in the
+// Location header (see /reassessments/{id}). An agreement that is being assessed,
+// periodically or by another job, cannot be re-assessed.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: from
+//   in: query
+//   description: Start of the interval (RFC3339)
+//   required: true
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: End of the interval (RFC3339); default is now
+//   type: string
+//   format: date-time
+// responses:
+//   '202':
+//     description: The started re-assessment
+//     schema:
+//       "$ref": "#/definitions/Reassessment"
+//   '400' :
+//     description: Wrong query parameters, or interval too long or with too many assessments
+//   '404' :
+//     description: Agreement not found
+//   '409' :
+//     description: The agreement is being assessed
+//   '500' :
+//     description: The re-assessment could not be started
+//   '503' :
+//     description: No monitoring is configured
+func (a *App) ReassessAgreement(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	from, err := parseTimeParam(v.Get("from"), "from")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := parseTimeParam(v.Get("to"), "to")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now()
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+	if from.IsZero() || !from.Before(to) {
+		respondWithError(w, http.StatusBadRequest, "Parameter from must be set and before to")
+		return
+	}
+	if a.maxReassess > 0 && to.Sub(from) > a.maxReassess {
+		respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("Interval from-to must not be longer than %v", a.maxReassess))
+		return
+	}
+	if a.scheduler == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "No monitoring adapter configured")
+		return
+	}
+	agreement, err := a.Repository.GetAgreement(mux.Vars(r)["id"])
+	if err != nil {
+		manageError(err, w)
+		return
+	}
+	if a.maxReassessSteps > 0 &&
+		a.scheduler.ReassessmentSteps(*agreement, from, to, a.maxReassessSteps) > a.maxReassessSteps {
+		respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("Interval from-to must not take more than %d assessments", a.maxReassessSteps))
+		return
+	}
+	job, err := a.reassessments.start(a.scheduler, *agreement, from, to)
+	if err == assessment.ErrAssessmentRunning {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/reassessments/"+job.Id)
+	respondWithJSON(w, http.StatusAccepted, job)
+}
+
+// GetReassessment gets the status of a re-assessment
+// swagger:operation GET /reassessments/{id} getReassessment
+//
+// Returns the status of the re-assessment whose ID is passed as parameter, and
+// the violations it raised once finished. Finished re-assessments are kept for a
+// day, and they are lost on restart.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the re-assessment
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The re-assessment with the ID
+//     schema:
+//       "$ref": "#/definitions/Reassessment"
+//   '404' :
+//     description: Re-assessment not found
+func (a *App) GetReassessment(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		return a.reassessments.get(id)
+	})
+}
+
+// reassessments keeps in memory the re-assessments started through the REST API,
+// until reassessmentRetention after they finish
+type reassessments struct {
+	sync.Mutex
+	jobs map[string]*model.Reassessment
+}
+
+func newReassessments() *reassessments {
+	return &reassessments{jobs: map[string]*model.Reassessment{}}
+}
+
+// start starts the re-assessment of an agreement with a scheduler (see
+// assessment.Scheduler.Reassess), and returns it.
+func (rs *reassessments) start(s *assessment.Scheduler, agreement model.Agreement,
+	from, to time.Time) (model.Reassessment, error) {
+
+	job := &model.Reassessment{
+		Id:          uuid.New().String(),
+		AgreementId: agreement.Id,
+		From:        from,
+		To:          to,
+		Status:      model.ReassessmentRunning,
+		Start:       time.Now(),
+		Violations:  []model.Violation{},
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	err := s.Reassess(context.Background(), agreement, from, to, func(violations []model.Violation, err error) {
+		rs.finish(job.Id, violations, err)
+	})
+	if err != nil {
+		return model.Reassessment{}, err
+	}
+	for id, other := range rs.jobs {
+		if other.End != nil && job.Start.Sub(*other.End) > reassessmentRetention {
+			delete(rs.jobs, id)
+		}
+	}
+	rs.jobs[job.Id] = job
+	return *job, nil
+}
+
+// finish sets the outcome of a re-assessment
+func (rs *reassessments) finish(id string, violations []model.Violation, err error) {
+	rs.Lock()
+	defer rs.Unlock()
+	job := rs.jobs[id]
+	end := time.Now()
+	job.End = &end
+	job.Status = model.ReassessmentDone
+	if violations != nil {
+		job.Violations = violations
+	}
+	if err != nil {
+		log.Warnf("Re-assessment %s of agreement %s failed: %s", id, job.AgreementId, err.Error())
+		job.Status = model.ReassessmentFailed
+		job.Error = err.Error()
+	}
+}
+
+// get returns a re-assessment, or model.ErrNotFound
+func (rs *reassessments) get(id string) (model.Reassessment, error) {
+	rs.Lock()
+	defer rs.Unlock()
+	job, ok := rs.jobs[id]
+	if !ok {
+		return model.Reassessment{}, model.ErrNotFound
+	}
+	return *job, nil
+}
+
+// evaluate evaluates a copy of the agreement, with an empty assessment,
+// against the metrics of the evaluation. Maintenance windows are not applied.
+func evaluate(ctx context.Context, agreement model.Agreement, in model.Evaluation) (amodel.Result, error) {
+	agreement.Assessment = model.Assessment{}
+
+	var now time.Time
+	if in.Now != nil {
+		now = *in.Now
+	} else {
+		for _, values := range in.Metrics {
+			for _, v := range values {
+				if v.DateTime.After(now) {
+					now = v.DateTime
+				}
+			}
+		}
+		if now.IsZero() {
+			now = time.Now()
+		}
+	}
+	retriever := genericadapter.MemoryRetriever(in.Metrics)
+	ma := genericadapter.New(retriever.Retrieve(), genericadapter.Aggregate)
+	return assessment.EvaluateAgreement(ctx, &agreement, ma, now, nil)
+}
+
+// checkEvaluationMetrics checks that the metric values of an evaluation are
+// numbers or valid histograms (see model.Histogram.Check), which are the values
+// that the aggregations accept
+func checkEvaluationMetrics(metrics map[string][]model.MetricValue) error {
+	for name, values := range metrics {
+		for _, v := range values {
+			if _, ok := v.Value.(float64); ok {
+				continue
+			}
+			if h, ok := v.AsHistogram(); ok {
+				if err := h.Check(); err != nil {
+					return fmt.Errorf("Invalid value of metric %s at %v: %s",
+						name, v.DateTime.Format(time.RFC3339), err.Error())
+				}
+				continue
+			}
+			return fmt.Errorf("Invalid value of metric %s at %v: %v is not a number nor a histogram",
+				name, v.DateTime.Format(time.RFC3339), v.Value)
+		}
+	}
+	return nil
+}
+
+// validationMessage joins the messages of validation errors
+func validationMessage(errs []error) string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, ". ")
+}
+
+// parseViolationQuery builds a ViolationQuery from the request query parameters
+func parseViolationQuery(r *http.Request) (model.ViolationQuery, error) {
+	v := r.URL.Query()
+	q := model.ViolationQuery{
+		Guarantee:  v.Get("guarantee"),
+		ProviderId: v.Get("provider"),
+		ClientId:   v.Get("client"),
+	}
+	var err error
+	if q.From, err = parseTimeParam(v.Get("from"), "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTimeParam(v.Get("to"), "to"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// parseTimeParam parses a RFC3339 time. An empty value returns the zero time.
+func parseTimeParam(value string, name string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("Invalid value for parameter %s: %s", name, value)
+	}
+	return t, nil
+}
+
 func manageError(err error, w http.ResponseWriter) {
 	switch err {
 	case model.ErrAlreadyExist:
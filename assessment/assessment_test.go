@@ -18,15 +18,18 @@ package assessment
 
 import (
 	assessment_model "SLALite/assessment/model"
+	"SLALite/assessment/monitor"
 	"SLALite/assessment/monitor/simpleadapter"
 	"SLALite/model"
+	"SLALite/repositories/memrepository"
+	"SLALite/repositories/validation"
 	"SLALite/utils"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
 	"time"
-
-	"github.com/Knetic/govaluate"
 )
 
 type ValidationNotifier struct {
@@ -45,6 +48,7 @@ func (n ValidationNotifier) NotifyViolations(agreement *model.Agreement, result
 	violations, ok := n.Expected[agreement.Id]
 	if ok {
 		checkAssessmentResult(n.T, agreement, *result, model.STARTED, violations, nil)
+		checkPersistedViolations(n.T, agreement, result.GetViolations())
 		updated, _ := repo.GetAgreement(agreement.Id)
 		if updated != nil {
 			checkTimes(n.T, agreement, updated.Assessment.FirstExecution, updated.Assessment.LastExecution)
@@ -64,6 +68,10 @@ func TestAssessActiveAgreements(t *testing.T) {
 
 	var aa1 = createAgreement("aa01", p1, c2, "Agreement aa01", "m >= 10")
 	aa1.State = model.STARTED
+	aa1.Details.Guarantees[0].Penalties = []model.PenaltyDef{
+		{Type: "discount", Value: "10", Unit: "%"},
+		{Type: "service", Value: "1", Unit: "month"},
+	}
 
 	guarantees := map[string]string{
 		"g1": "m >= 20",
@@ -97,7 +105,7 @@ func TestAssessActiveAgreements(t *testing.T) {
 		},
 	}
 
-	AssessActiveAgreements(repo, simpleadapter.New(m1), ValidationNotifier{Expected: map[string]map[string]int{
+	AssessActiveAgreements(context.Background(), repo, simpleadapter.New(m1), ValidationNotifier{Expected: map[string]map[string]int{
 		"aa01": map[string]int{
 			"TestGuarantee": 2,
 		},
@@ -108,7 +116,492 @@ func TestAssessActiveAgreements(t *testing.T) {
 		"aa03": map[string]int{
 			"g1": 1,
 		},
-	}, T: t})
+	}, T: t}, Config{Workers: 2})
+}
+
+func TestAssessActiveAgreementsWithMaintenance(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	am := createAgreement("am01", p1, c2, "Agreement am01", "m >= 0")
+	am.State = model.STARTED
+	am.Details.Guarantees[0].Penalties = []model.PenaltyDef{{Type: "discount", Value: "10", Unit: "%"}}
+	repo.CreateAgreement(&am)
+	repo.CreateMaintenanceWindow(&model.MaintenanceWindow{
+		Id: "w_tag", AgreementId: am.Id, Start: t_(0), End: t_(1), Action: model.MaintenanceTag,
+	})
+	repo.CreateMaintenanceWindow(&model.MaintenanceWindow{
+		Id: "w_skip", ProviderId: p1.Id, Start: t_(2), End: t_(3),
+	})
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(2)}},
+	}
+	AssessActiveAgreements(context.Background(), repo, simpleadapter.New(values), nil, Config{})
+
+	violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: am.Id})
+	if len(violations) != 2 {
+		t.Fatalf("Unexpected violations. Expected: 2. Actual: %v", violations)
+	}
+	if violations[0].Maintenance != "w_tag" || violations[1].Maintenance != "" {
+		t.Errorf("Unexpected maintenance of violations: %v", violations)
+	}
+	if penalties, _ := repo.GetPenalties(model.PenaltyQuery{AgreementId: am.Id}); len(penalties) != 1 ||
+		penalties[0].ViolationId != violations[1].Id {
+		t.Errorf("Unexpected penalties: %v", penalties)
+	}
+}
+
+// recordingNotifier records the notified violations and recoveries
+type recordingNotifier struct {
+	violations []model.Violation
+	recoveries []model.Incident
+}
+
+func (n *recordingNotifier) NotifyViolations(agreement *model.Agreement, result *assessment_model.Result) {
+	n.violations = append(n.violations, result.GetViolations()...)
+}
+
+func (n *recordingNotifier) NotifyRecoveries(agreement *model.Agreement, incidents []model.Incident) {
+	n.recoveries = append(n.recoveries, incidents...)
+}
+
+func TestAssessActiveAgreementsWithTaggedViolation(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	am := createAgreement("am02", p1, c2, "Agreement am02", "m >= 0")
+	am.State = model.STARTED
+	repo.CreateAgreement(&am)
+	repo.CreateMaintenanceWindow(&model.MaintenanceWindow{
+		Id: "w_tag", AgreementId: am.Id, Start: t_(0), End: t_(1), Action: model.MaintenanceTag,
+	})
+	not := &recordingNotifier{}
+
+	/* tagged violation: persisted, but neither notified nor opening an incident */
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	AssessActiveAgreements(context.Background(), repo, simpleadapter.New(values), not, Config{})
+	violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: am.Id})
+	if len(violations) != 1 || violations[0].Maintenance != "w_tag" {
+		t.Fatalf("Expected a tagged violation. Actual: %v", violations)
+	}
+	if len(not.violations) != 0 {
+		t.Errorf("Unexpected notified violations: %v", not.violations)
+	}
+
+	/* healthy points: nothing to recover */
+	values = assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(2)}},
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(3)}},
+	}
+	AssessActiveAgreements(context.Background(), repo, simpleadapter.New(values), not, Config{})
+	if incidents, _ := repo.GetIncidents(model.IncidentQuery{AgreementId: am.Id}); len(incidents) != 0 {
+		t.Errorf("Unexpected incidents: %v", incidents)
+	}
+	if len(not.recoveries) != 0 {
+		t.Errorf("Unexpected notified recoveries: %v", not.recoveries)
+	}
+	updated, _ := repo.GetAgreement(am.Id)
+	if ag := updated.Assessment.GetGuarantee("TestGuarantee"); ag.Incident != "" || ag.Status != model.FULFILLED {
+		t.Errorf("Unexpected assessment of guarantee: %v", ag)
+	}
+}
+
+// slowAdapter delays the values returned by a MonitoringAdapter
+type slowAdapter struct {
+	monitor.MonitoringAdapter
+	delay time.Duration
+}
+
+func (ma slowAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	return slowAdapter{MonitoringAdapter: ma.MonitoringAdapter.Initialize(a), delay: ma.delay}
+}
+
+func (ma slowAdapter) GetValues(gt model.Guarantee, vars []string, now time.Time) assessment_model.GuaranteeData {
+	time.Sleep(ma.delay)
+	return ma.MonitoringAdapter.GetValues(gt, vars, now)
+}
+
+func TestAssessActiveAgreementsWithTimeout(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	at := createAgreement("at01", p1, c2, "Agreement at01", "m >= 0")
+	at.State = model.STARTED
+	at.Assessment.SetGuarantee("TestGuarantee", model.AssessmentGuarantee{
+		LastValues: model.LastValues{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(-1)}},
+	})
+	repo.CreateAgreement(&at)
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	ma := slowAdapter{MonitoringAdapter: simpleadapter.New(values), delay: 100 * time.Millisecond}
+
+	AssessActiveAgreements(context.Background(), repo, ma, nil, Config{Workers: 2, Timeout: 10 * time.Millisecond})
+	updated, _ := repo.GetAgreement(at.Id)
+	if !updated.Assessment.TimedOut {
+		t.Errorf("Agreement %s expected to be timed out", at.Id)
+	}
+	if !updated.Assessment.LastExecution.IsZero() {
+		t.Errorf("Unexpected lastExecution in timed out agreement: %v", updated.Assessment.LastExecution)
+	}
+	if violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: at.Id}); len(violations) != 0 {
+		t.Errorf("Unexpected violations in timed out agreement: %v", violations)
+	}
+
+	time.Sleep(2 * ma.delay)
+	AssessActiveAgreements(context.Background(), repo, ma, nil, Config{Workers: 2, Timeout: time.Second})
+	updated, _ = repo.GetAgreement(at.Id)
+	if updated.Assessment.TimedOut {
+		t.Errorf("Agreement %s not expected to be timed out", at.Id)
+	}
+	if violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: at.Id}); len(violations) != 1 {
+		t.Errorf("Unexpected violations. Expected: 1. Actual: %v", violations)
+	}
+}
+
+func TestSchedulerSkipsRunningAgreements(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	at := createAgreement("at02", p1, c2, "Agreement at02", "m >= 0")
+	at.State = model.STARTED
+	repo.CreateAgreement(&at)
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	ma := slowAdapter{MonitoringAdapter: simpleadapter.New(values), delay: 100 * time.Millisecond}
+	s := NewScheduler(repo, ma, nil, Config{Period: time.Minute, Timeout: 10 * time.Millisecond})
+	now := time.Now()
+
+	s.AssessDueAgreements(context.Background(), now)
+	updated, _ := repo.GetAgreement(at.Id)
+	if !updated.Assessment.TimedOut {
+		t.Errorf("Agreement %s expected to be timed out", at.Id)
+	}
+
+	/* the first assessment is still running: the agreement is skipped and not updated */
+	s.cfg.Timeout = time.Second
+	s.AssessDueAgreements(context.Background(), now.Add(time.Minute))
+	updated, _ = repo.GetAgreement(at.Id)
+	if !updated.Assessment.TimedOut || !updated.Assessment.LastExecution.IsZero() {
+		t.Errorf("Agreement %s expected to be skipped while previous assessment is running", at.Id)
+	}
+
+	/* a different scheduler does not share the agreements being assessed */
+	other := NewScheduler(repo, ma, nil, Config{Period: time.Minute})
+	if !other.running.add(at.Id) {
+		t.Errorf("Agreement %s not expected to be running in a different scheduler", at.Id)
+	}
+
+	time.Sleep(2 * ma.delay)
+	s.AssessDueAgreements(context.Background(), now.Add(2*time.Minute))
+	updated, _ = repo.GetAgreement(at.Id)
+	if updated.Assessment.TimedOut || updated.Assessment.LastExecution.IsZero() {
+		t.Errorf("Agreement %s expected to be assessed", at.Id)
+	}
+	if violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: at.Id}); len(violations) != 1 {
+		t.Errorf("Unexpected violations. Expected: 1. Actual: %v", violations)
+	}
+}
+
+// blockingAdapter is a monitoring adapter that does not return the values until
+// release is closed
+type blockingAdapter struct {
+	monitor.MonitoringAdapter
+	release chan bool
+}
+
+func (ma blockingAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	return blockingAdapter{MonitoringAdapter: ma.MonitoringAdapter.Initialize(a), release: ma.release}
+}
+
+func (ma blockingAdapter) GetValues(gt model.Guarantee, vars []string, now time.Time) assessment_model.GuaranteeData {
+	<-ma.release
+	return ma.MonitoringAdapter.GetValues(gt, vars, now)
+}
+
+func TestSchedulerReassess(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	ar := createAgreement("ar01", p1, c2, "Agreement ar01", "m >= 0")
+	ar.State = model.STARTED
+	repo.CreateAgreement(&ar)
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(-30)}},
+	}
+	ma := blockingAdapter{MonitoringAdapter: simpleadapter.New(values), release: make(chan bool)}
+	s := NewScheduler(repo, ma, nil, Config{Period: time.Minute})
+	done := make(chan []model.Violation, 1)
+	reassess := func() error {
+		return s.Reassess(context.Background(), ar, t_(-60), t_(0), func(violations []model.Violation, err error) {
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			done <- violations
+		})
+	}
+
+	if err := reassess(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	/* the agreement is being re-assessed: it is neither re-assessed nor assessed */
+	if err := reassess(); err != ErrAssessmentRunning {
+		t.Errorf("Expected ErrAssessmentRunning. Actual: %v", err)
+	}
+	s.AssessDueAgreements(context.Background(), time.Now())
+	if updated, _ := repo.GetAgreement(ar.Id); !updated.Assessment.LastExecution.IsZero() {
+		t.Errorf("Agreement %s expected to be skipped while it is re-assessed", ar.Id)
+	}
+
+	close(ma.release)
+	if violations := <-done; len(violations) != 1 {
+		t.Errorf("Unexpected violations. Expected: 1. Actual: %v", violations)
+	}
+	if err := reassess(); err != nil {
+		t.Errorf("Unexpected error after the re-assessment finished: %v", err)
+	}
+	<-done
+}
+
+// nowAdapter is a monitoring adapter that returns a value of m at the time of the assessment
+type nowAdapter struct {
+	value float64
+}
+
+func (ma nowAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	return ma
+}
+
+func (ma nowAdapter) GetValues(gt model.Guarantee, vars []string, now time.Time) assessment_model.GuaranteeData {
+	return assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: ma.value, DateTime: now}},
+	}
+}
+
+func TestSchedulerReassessDuringAssessment(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	ar := createAgreement("ar02", p1, c2, "Agreement ar02", "m >= 0")
+	ar.State = model.STARTED
+	repo.CreateAgreement(&ar)
+	as := createAgreement("ar03", p1, c2, "Agreement ar03", "m >= 0")
+	as.State = model.STARTED
+	repo.CreateAgreement(&as)
+
+	s := NewScheduler(repo, nowAdapter{value: -1}, nil, Config{Period: time.Second})
+	now := time.Now()
+	done := make(chan []model.Violation, 1)
+	err := s.Reassess(context.Background(), ar, now.Add(-500*time.Second), now,
+		func(violations []model.Violation, err error) {
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			done <- violations
+		})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	/* the periodic assessment persists its results while the re-assessment persists its violations */
+	for i := 0; i < 20; i++ {
+		s.AssessDueAgreements(context.Background(), now.Add(time.Duration(i)*time.Second))
+	}
+
+	if violations := <-done; len(violations) != 500 {
+		t.Errorf("Unexpected violations. Expected: 500. Actual: %d", len(violations))
+	}
+	if violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: as.Id}); len(violations) == 0 {
+		t.Errorf("Agreement %s expected to be assessed while %s is re-assessed", as.Id, ar.Id)
+	}
+}
+
+func TestSchedulerReassessmentSteps(t *testing.T) {
+	a := createAgreement("ar04", p1, c2, "Agreement ar04", "m >= 0")
+	s := NewScheduler(repo, nil, nil, Config{Period: 10 * time.Second})
+
+	if steps := s.ReassessmentSteps(a, t_(0), t_(35), 10); steps != 4 {
+		t.Errorf("Unexpected steps. Expected: 4. Actual: %d", steps)
+	}
+	a.Details.Schedule = "PT1S"
+	if steps := s.ReassessmentSteps(a, t_(0), t_(35), 10); steps != 11 {
+		t.Errorf("Unexpected steps over the limit. Expected: 11. Actual: %d", steps)
+	}
+	if steps := s.ReassessmentSteps(a, t_(0), t_(0), 10); steps != 0 {
+		t.Errorf("Unexpected steps of empty interval. Expected: 0. Actual: %d", steps)
+	}
+}
+
+func TestSchedulerAssessDueAgreements(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	fast := createAgreement("as01", p1, c2, "Agreement as01", "m >= 0")
+	fast.State = model.STARTED
+	fast.Details.Schedule = "PT10S"
+	repo.CreateAgreement(&fast)
+	slow := createAgreement("as02", p1, c2, "Agreement as02", "m >= 0")
+	slow.State = model.STARTED
+	repo.CreateAgreement(&slow)
+
+	lastExecution := func(id string) time.Time {
+		a, _ := repo.GetAgreement(id)
+		return a.Assessment.LastExecution
+	}
+
+	s := NewScheduler(repo, simpleadapter.New(assessment_model.GuaranteeData{}), nil, Config{Period: 10 * time.Minute})
+	now := time.Now()
+	next := s.AssessDueAgreements(context.Background(), now)
+	if wait := next.Sub(now); wait < 9*time.Second || wait > 11*time.Second {
+		t.Errorf("Unexpected next assessment after %v", wait)
+	}
+	fastLast, slowLast := lastExecution(fast.Id), lastExecution(slow.Id)
+	if fastLast.IsZero() || slowLast.IsZero() {
+		t.Fatalf("Agreements expected to be assessed")
+	}
+
+	s.AssessDueAgreements(context.Background(), now.Add(5*time.Second))
+	if !lastExecution(fast.Id).Equal(fastLast) || !lastExecution(slow.Id).Equal(slowLast) {
+		t.Errorf("Agreements not expected to be assessed")
+	}
+
+	s.AssessDueAgreements(context.Background(), now.Add(11*time.Second))
+	if lastExecution(fast.Id).Equal(fastLast) {
+		t.Errorf("Agreement %s expected to be assessed", fast.Id)
+	}
+	if !lastExecution(slow.Id).Equal(slowLast) {
+		t.Errorf("Agreement %s not expected to be assessed", slow.Id)
+	}
+	fastLast = lastExecution(fast.Id)
+
+	s.AssessDueAgreements(context.Background(), now.Add(12*time.Second))
+	if !lastExecution(fast.Id).Equal(fastLast) {
+		t.Errorf("Agreement %s not expected to be assessed", fast.Id)
+	}
+}
+
+func TestCopyAgreement(t *testing.T) {
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	a.Assessment.SetGuarantee("TestGuarantee", model.AssessmentGuarantee{
+		LastValues: model.LastValues{"m": model.MetricValue{Key: "m", Value: 1}},
+	})
+
+	c := copyAgreement(a)
+	c.Assessment.GetGuarantee("TestGuarantee").LastValues["m"] = model.MetricValue{Key: "m", Value: 2}
+	c.Assessment.SetGuarantee("other", model.AssessmentGuarantee{})
+
+	if a.Assessment.GetGuarantee("TestGuarantee").LastValues["m"].Value != 1 {
+		t.Errorf("Copied agreement shares LastValues with original")
+	}
+	if len(a.Assessment.Guarantees) != 1 {
+		t.Errorf("Copied agreement shares Guarantees with original")
+	}
+}
+
+func TestPersistIncidents(t *testing.T) {
+	repo, _ := memrepository.New(nil)
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	a.State = model.STARTED
+	gtname := a.Details.Guarantees[0].Name
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -2, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(2)}},
+		{"m": model.MetricValue{Key: "m", Value: -3, DateTime: t_(3)}},
+	}
+	result := AssessAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	if recovered := result.Recovered[gtname]; len(recovered) != 1 || !recovered[0].Equal(t_(2)) {
+		t.Errorf("Unexpected recoveries. Expected: [%v]. Actual: %v", t_(2), recovered)
+	}
+	persistViolations(repo, &a, &result, false)
+	closed := persistIncidents(repo, &a, &result, false)
+	if len(closed) != 1 {
+		t.Fatalf("Unexpected closed incidents. Expected: 1. Actual: %v", closed)
+	}
+	if i := closed[0]; !i.Start.Equal(t_(0)) || !i.End.Equal(t_(2)) || len(i.Violations) != 2 {
+		t.Errorf("Unexpected closed incident: %v", i)
+	}
+	open := a.Assessment.GetGuarantee(gtname).Incident
+	incident, err := repo.GetIncident(open)
+	if err != nil || !incident.IsOpen() || !incident.Start.Equal(t_(3)) {
+		t.Fatalf("Unexpected open incident %s: %v (%v)", open, incident, err)
+	}
+
+	/* open incident is continued and then closed on next assessment */
+	values = assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -4, DateTime: t_(4)}},
+		{"m": model.MetricValue{Key: "m", Value: 2, DateTime: t_(5)}},
+	}
+	result = AssessAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	persistViolations(repo, &a, &result, false)
+	closed = persistIncidents(repo, &a, &result, false)
+	if len(closed) != 1 || closed[0].Id != open || len(closed[0].Violations) != 2 {
+		t.Errorf("Unexpected closed incidents: %v", closed)
+	}
+	if id := a.Assessment.GetGuarantee(gtname).Incident; id != "" {
+		t.Errorf("Unexpected open incident: %s", id)
+	}
+	incidents, _ := repo.GetIncidents(model.IncidentQuery{AgreementId: a.Id})
+	if len(incidents) != 2 || incidents[1].IsOpen() || incidents[1].Duration != 2 {
+		t.Errorf("Unexpected persisted incidents: %v", incidents)
+	}
+}
+
+// idRepository is a repository that sets the ids of the created violations,
+// penalties and incidents, as expected with externalIDs
+type idRepository struct {
+	model.IRepository
+	next int
+}
+
+func (r *idRepository) newID(prefix string) string {
+	r.next++
+	return fmt.Sprintf("%s%d", prefix, r.next)
+}
+
+func (r *idRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	v.Id = r.newID("v")
+	return r.IRepository.CreateViolation(v)
+}
+
+func (r *idRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	p.Id = r.newID("p")
+	return r.IRepository.CreatePenalty(p)
+}
+
+func (r *idRepository) CreateIncident(i *model.Incident) (*model.Incident, error) {
+	i.Id = r.newID("i")
+	return r.IRepository.CreateIncident(i)
+}
+
+func TestPersistWithExternalIDs(t *testing.T) {
+	mem, _ := memrepository.New(nil)
+	backend := &idRepository{IRepository: mem}
+	repo, _ := validation.New(backend, model.NewDefaultValidator(true, false))
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	a.State = model.STARTED
+	a.Details.Guarantees[0].Penalties = []model.PenaltyDef{{Type: "discount", Value: "10", Unit: "%"}}
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -2, DateTime: t_(1)}},
+	}
+	result := AssessAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	persistViolations(repo, &a, &result, true)
+	persistIncidents(repo, &a, &result, true)
+
+	violations, _ := repo.GetViolations(model.ViolationQuery{AgreementId: a.Id})
+	if len(violations) != 2 {
+		t.Fatalf("Unexpected persisted violations. Expected: 2. Actual: %v", violations)
+	}
+	for _, v := range result.GetViolations() {
+		if v.Id == "" {
+			t.Errorf("Violation without the id set by the repository: %v", v)
+		}
+		penalties, _ := repo.GetPenalties(model.PenaltyQuery{ViolationId: v.Id})
+		if len(penalties) != 1 || penalties[0].Id == "" {
+			t.Errorf("Unexpected penalties of violation %s: %v", v.Id, penalties)
+		}
+	}
+	incident := a.Assessment.GetGuarantee(a.Details.Guarantees[0].Name).Incident
+	if _, err := repo.GetIncident(incident); incident == "" || err != nil {
+		t.Errorf("Unexpected open incident '%s': %v", incident, err)
+	}
 }
 
 func TestAssessAgreement(t *testing.T) {
@@ -123,11 +616,11 @@ func TestAssessAgreement(t *testing.T) {
 	expectedLast := map[string]model.LastValues{}
 
 	a2.State = model.STOPPED
-	result := AssessAgreement(&a2, ma, t0)
+	result := AssessAgreement(context.Background(), &a2, ma, t0, nil)
 	checkAssessmentResult(t, &a2, result, model.STOPPED, expected, expectedLast)
 
 	a2.State = model.TERMINATED
-	result = AssessAgreement(&a2, ma, t0)
+	result = AssessAgreement(context.Background(), &a2, ma, t0, nil)
 	checkAssessmentResult(t, &a2, result, model.TERMINATED, expected, expectedLast)
 
 	a2.State = model.STARTED
@@ -137,18 +630,130 @@ func TestAssessAgreement(t *testing.T) {
 			"m": values[1]["m"],
 		},
 	}
-	result = AssessAgreement(&a2, ma, t0)
+	result = AssessAgreement(context.Background(), &a2, ma, t0, nil)
 	checkAssessmentResult(t, &a2, result, model.STARTED, expected, expectedLast)
 	checkTimes(t, &a2, t0, t0)
 
 	t1 := t_(1)
-	result = AssessAgreement(&a2, ma, t1)
+	result = AssessAgreement(context.Background(), &a2, ma, t1, nil)
 	checkTimes(t, &a2, t0, t1)
 
 	// check assessment without values
 	values = assessment_model.GuaranteeData{}
 	ma = simpleadapter.New(values)
-	result = AssessAgreement(&a2, ma, t0)
+	result = AssessAgreement(context.Background(), &a2, ma, t0, nil)
+}
+
+// historyAdapter returns the values since the last execution of the guarantee term
+type historyAdapter struct {
+	agreement *model.Agreement
+	values    assessment_model.GuaranteeData
+}
+
+func (ma historyAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	ma.agreement = a
+	return ma
+}
+
+func (ma historyAdapter) GetValues(gt model.Guarantee, vars []string, now time.Time) assessment_model.GuaranteeData {
+	from := getDefaultFrom(ma.agreement, gt)
+	result := assessment_model.GuaranteeData{}
+	for _, data := range ma.values {
+		if t := data[vars[0]].DateTime; t.After(from) && !t.After(now) {
+			result = append(result, data)
+		}
+	}
+	return result
+}
+
+func TestReassessAgreement(t *testing.T) {
+	a := createAgreement("a_reassess", p1, c2, "Agreement to reassess", "m >= 0")
+	a.State = model.STOPPED
+	a.Assessment.LastExecution = t_(100)
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(5)}},
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(15)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(25)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(35)}},
+	}
+	ma := historyAdapter{values: values}
+
+	violations, err := ReassessAgreement(context.Background(), repo, a, ma, t_(0), t_(30), Config{Period: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations. Actual: %v", violations)
+	}
+	for _, v := range violations {
+		if !v.Backfilled {
+			t.Errorf("Violation not marked as backfilled: %v", v)
+		}
+		if stored, err := repo.GetViolation(v.Id); err != nil || !stored.Backfilled {
+			t.Errorf("Violation %s not persisted as backfilled: %v %v", v.Id, stored, err)
+		}
+	}
+	if a.State != model.STOPPED || !a.Assessment.LastExecution.Equal(t_(100)) {
+		t.Errorf("Input agreement was modified: %v", a)
+	}
+
+	/* the stored violations are not raised again */
+	values = append(values, assessment_model.ExpressionData{
+		"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(38)},
+	})
+	ma = historyAdapter{values: values}
+	violations, err = ReassessAgreement(context.Background(), repo, a, ma, t_(0), t_(40), Config{Period: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(violations) != 2 || !violations[0].Datetime.Equal(t_(35)) {
+		t.Errorf("Expected only the violations after t_(30). Actual: %v", violations)
+	}
+	if stored, _ := repo.GetViolations(model.ViolationQuery{AgreementId: a.Id}); len(stored) != 4 {
+		t.Errorf("Expected 4 stored violations. Actual: %v", stored)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReassessAgreement(ctx, repo, a, ma, t_(0), t_(30), Config{Period: 10 * time.Second}); err == nil {
+		t.Errorf("Expected error on cancelled context")
+	}
+}
+
+// recordingAdapter records the times a MonitoringAdapter is asked for values
+type recordingAdapter struct {
+	monitor.MonitoringAdapter
+	times *[]time.Time
+}
+
+func (ma recordingAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	return recordingAdapter{MonitoringAdapter: ma.MonitoringAdapter.Initialize(a), times: ma.times}
+}
+
+func (ma recordingAdapter) GetValues(gt model.Guarantee, vars []string, now time.Time) assessment_model.GuaranteeData {
+	*ma.times = append(*ma.times, now)
+	return ma.MonitoringAdapter.GetValues(gt, vars, now)
+}
+
+func TestReassessAgreementWithSchedule(t *testing.T) {
+	a := createAgreement("a_reassess_schedule", p1, c2, "Agreement to reassess", "m >= 0")
+	a.Details.Schedule = "PT20S"
+	times := []time.Time{}
+	ma := recordingAdapter{MonitoringAdapter: historyAdapter{}, times: &times}
+
+	_, err := ReassessAgreement(context.Background(), repo, a, ma, t_(0), t_(50), Config{Period: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []time.Time{t_(20), t_(40), t_(50)}
+	if len(times) != len(expected) {
+		t.Fatalf("Unexpected assessment times. Expected: %v. Actual: %v", expected, times)
+	}
+	for i := range expected {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("Unexpected assessment time. Expected: %v. Actual: %v", expected[i], times[i])
+		}
+	}
 }
 
 func checkAssessmentResult(t *testing.T, a *model.Agreement,
@@ -187,6 +792,27 @@ func checkAssessmentResult(t *testing.T, a *model.Agreement,
 	}
 }
 
+func checkPersistedViolations(t *testing.T, a *model.Agreement, violations []model.Violation) {
+	for _, v := range violations {
+		if v.Id == "" {
+			t.Errorf("Violation of guarantee %s has no id", v.Guarantee)
+			continue
+		}
+		if _, err := repo.GetViolation(v.Id); err != nil {
+			t.Errorf("Violation %s not persisted: %v", v.Id, err)
+		}
+		gt, _ := a.Details.GetGuarantee(v.Guarantee)
+		penalties, err := repo.GetPenalties(model.PenaltyQuery{ViolationId: v.Id})
+		if err != nil {
+			t.Errorf("Unexpected error getting penalties of violation %s: %v", v.Id, err)
+		}
+		if len(penalties) != len(gt.Penalties) {
+			t.Errorf("Unexpected penalties of violation %s. Expected: %d. Actual: %d",
+				v.Id, len(gt.Penalties), len(penalties))
+		}
+	}
+}
+
 func checkTimes(t *testing.T, a *model.Agreement, expectedFirst time.Time, expectedLast time.Time) {
 
 	if a.Assessment.FirstExecution.Unix() != expectedFirst.Unix() {
@@ -204,7 +830,7 @@ func TestAssessExpiredAgreement(t *testing.T) {
 	a2.State = model.STARTED
 	expiration := t_(-1)
 	a2.Details.Expiration = &expiration
-	result := AssessAgreement(&a2, ma, t0)
+	result := AssessAgreement(context.Background(), &a2, ma, t0, nil)
 	if a2.State != model.TERMINATED {
 		t.Errorf("Agreement in unexpected state. Expected: terminated. Actual: %v", a2.State)
 	}
@@ -219,7 +845,7 @@ func TestEvaluateAgreement(t *testing.T) {
 		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(1)}},
 	}
 	ma := simpleadapter.New(values)
-	invalid, err := EvaluateAgreement(&a1, ma, time.Now())
+	invalid, err := EvaluateAgreement(context.Background(), &a1, ma, time.Now(), nil)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -254,9 +880,387 @@ func TestEvaluateAgreementWithWrongValues(t *testing.T) {
 		{"n": model.MetricValue{Key: "n", Value: 1, DateTime: t_(0)}},
 	}
 	ma := simpleadapter.New(values)
-	_, err := EvaluateAgreement(&a1, ma, time.Now())
-	if err == nil {
-		t.Errorf("Expected error evaluating agreement")
+	result, err := EvaluateAgreement(context.Background(), &a1, ma, time.Now(), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if e, ok := result.Errors["TestGuarantee"]; !ok || e.Message == "" {
+		t.Errorf("Expected error evaluating guarantee. Result: %v", result)
+	}
+}
+
+func TestAssessAgreementWithPartialErrors(t *testing.T) {
+	a := createAgreementFull("a_partial", p1, c2, "Agreement with a broken term",
+		map[string]string{"ok": "m >= 0", "broken": "n >= 0"}, nil)
+	a.State = model.STARTED
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	ma := simpleadapter.New(values)
+
+	result := AssessAgreement(context.Background(), &a, ma, t0, nil)
+	if len(result.Violated["ok"].Violations) != 1 {
+		t.Errorf("Expected 1 violation of the valid term. Result: %v", result)
+	}
+	if _, ok := result.Errors["broken"]; !ok {
+		t.Errorf("Expected error in the broken term. Result: %v", result)
+	}
+	if !a.Assessment.LastExecution.Equal(t0) {
+		t.Errorf("Assessment not updated: %v", a.Assessment)
+	}
+	if ag := a.Assessment.GetGuarantee("ok"); ag.Error != nil || !ag.LastExecution.Equal(t0) {
+		t.Errorf("Unexpected assessment of the valid term: %v", ag)
+	}
+	broken := a.Assessment.GetGuarantee("broken")
+	if broken.Error == nil || !broken.Error.Datetime.Equal(t0) || !broken.LastExecution.IsZero() {
+		t.Errorf("Unexpected assessment of the broken term: %v", broken)
+	}
+
+	a.Details.Guarantees = []model.Guarantee{{Name: "broken", Constraint: "m >= -2"}}
+	AssessAgreement(context.Background(), &a, ma, t_(1), nil)
+	if broken := a.Assessment.GetGuarantee("broken"); broken.Error != nil {
+		t.Errorf("Error not cleared after a successful evaluation: %v", broken)
+	}
+}
+
+// failingAdapter fails to retrieve the values of some guarantee terms
+type failingAdapter struct {
+	monitor.MonitoringAdapter
+	failing string
+}
+
+func (ma failingAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	return failingAdapter{MonitoringAdapter: ma.MonitoringAdapter.Initialize(a), failing: ma.failing}
+}
+
+func (ma failingAdapter) GetValuesContext(ctx context.Context, gt model.Guarantee, vars []string,
+	now time.Time) (assessment_model.GuaranteeData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if gt.Name == ma.failing {
+		return nil, errors.New("monitoring is down")
+	}
+	return ma.MonitoringAdapter.GetValues(gt, vars, now), nil
+}
+
+func TestEvaluateAgreementWithRetrievalError(t *testing.T) {
+	a := createAgreementFull("a_retrieval", p1, c2, "Agreement with an unavailable metric",
+		map[string]string{"ok": "m >= 0", "unavailable": "m >= 0"}, nil)
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	ma := failingAdapter{MonitoringAdapter: simpleadapter.New(values), failing: "unavailable"}
+
+	result, err := EvaluateAgreement(context.Background(), &a, ma, t0, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Violated["ok"].Violations) != 1 {
+		t.Errorf("Expected 1 violation of the available term. Result: %v", result)
+	}
+	if e, ok := result.Errors["unavailable"]; !ok || !e.Datetime.Equal(t0) {
+		t.Errorf("Expected retrieval error in the unavailable term. Result: %v", result.Errors)
+	}
+	if _, ok := result.Errors["ok"]; ok {
+		t.Errorf("Unexpected error in the available term: %v", result.Errors["ok"])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := EvaluateAgreement(ctx, &a, ma, t0, nil); err == nil {
+		t.Errorf("Expected error on cancelled context")
+	}
+}
+
+func TestEvaluateAgreementWithSchedule(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	ma := simpleadapter.New(values)
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	gt := &a.Details.Guarantees[0]
+	gt.Schedule = "PT1H"
+
+	result, err := EvaluateAgreement(context.Background(), &a, ma, t0, nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, ok := result.LastExecution[gt.Name]; !ok {
+		t.Errorf("Guarantee %s never evaluated must be due", gt.Name)
+	}
+	updateAssessment(&a, result, t0)
+
+	result, _ = EvaluateAgreement(context.Background(), &a, ma, t0.Add(30*time.Minute), nil)
+	if _, ok := result.LastExecution[gt.Name]; ok {
+		t.Errorf("Guarantee %s must not be due before schedule %s", gt.Name, gt.Schedule)
+	}
+	if len(result.Violated) != 0 {
+		t.Errorf("Unexpected violated GTs. Expected: 0. Actual:%v", len(result.Violated))
+	}
+
+	result, _ = EvaluateAgreement(context.Background(), &a, ma, t0.Add(time.Hour), nil)
+	if _, ok := result.LastExecution[gt.Name]; !ok {
+		t.Errorf("Guarantee %s must be due after schedule %s", gt.Name, gt.Schedule)
+	}
+}
+
+func TestEvaluateAgreementWithTolerance(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -2, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(2)}},
+		{"m": model.MetricValue{Key: "m", Value: -3, DateTime: t_(3)}},
+	}
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	gt := &a.Details.Guarantees[0]
+	gt.Tolerance = &model.Tolerance{Points: 2}
+
+	result, err := EvaluateAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	violated := result.Violated[gt.Name]
+	if len(violated.Violations) != 1 || violated.Metrics[0]["m"].Value != -2 {
+		t.Errorf("Unexpected violations. Expected: [m=-2]. Actual: %v", violated.Metrics)
+	}
+	updateAssessment(&a, result, t0)
+	failing := a.Assessment.GetGuarantee(gt.Name).Failing
+	if failing == nil || failing.Points != 1 || !failing.Since.Equal(t_(3)) {
+		t.Fatalf("Unexpected failing streak: %v", failing)
+	}
+
+	/* streak continues on next evaluation */
+	values = assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -4, DateTime: t_(4)}},
+	}
+	result, _ = EvaluateAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	if len(result.Violated[gt.Name].Violations) != 1 {
+		t.Errorf("Unexpected violations. Expected: [m=-4]. Actual: %v", result.Violated[gt.Name].Metrics)
+	}
+	if failing = result.Failing[gt.Name]; failing == nil || failing.Points != 2 {
+		t.Errorf("Unexpected failing streak: %v", failing)
+	}
+
+	/* duration tolerance */
+	a = createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	gt = &a.Details.Guarantees[0]
+	gt.Tolerance = &model.Tolerance{Duration: 2}
+	values = assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -2, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: -3, DateTime: t_(2)}},
+	}
+	result, _ = EvaluateAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	violated = result.Violated[gt.Name]
+	if len(violated.Violations) != 1 || violated.Metrics[0]["m"].Value != -3 {
+		t.Errorf("Unexpected violations. Expected: [m=-3]. Actual: %v", violated.Metrics)
+	}
+}
+
+// scopedAdapter returns the values of the scope member of a guarantee term
+type scopedAdapter map[string]assessment_model.GuaranteeData
+
+func (ma scopedAdapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
+	return ma
+}
+
+func (ma scopedAdapter) GetValues(gt model.Guarantee, vars []string, now time.Time) assessment_model.GuaranteeData {
+	return ma[gt.Member()]
+}
+
+func TestEvaluateAgreementWithScope(t *testing.T) {
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	gt := &a.Details.Guarantees[0]
+	gt.Scope = model.Scope{"host-a", "host-b"}
+	ma := scopedAdapter{
+		"host-a": {
+			{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(0)}},
+		},
+		"host-b": {
+			{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+			{"m": model.MetricValue{Key: "m", Value: -2, DateTime: t_(1)}},
+		},
+	}
+	result, err := EvaluateAgreement(context.Background(), &a, ma, t0, nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	keyA, keyB := gt.Name+"[host-a]", gt.Name+"[host-b]"
+	if _, ok := result.Violated[keyA]; ok {
+		t.Errorf("Unexpected violations of %s: %v", keyA, result.Violated[keyA])
+	}
+	violations := result.Violated[keyB].Violations
+	if len(violations) != 2 || violations[0].Member != "host-b" || violations[0].Guarantee != gt.Name {
+		t.Errorf("Unexpected violations of %s: %v", keyB, violations)
+	}
+
+	updateAssessment(&a, result, t0)
+	if v := a.Assessment.GetGuarantee(keyA).LastValues["m"].Value; v != 1 {
+		t.Errorf("Unexpected last value of %s. Expected: 1. Actual: %v", keyA, v)
+	}
+	if v := a.Assessment.GetGuarantee(keyB).LastValues["m"].Value; v != -2 {
+		t.Errorf("Unexpected last value of %s. Expected: -2. Actual: %v", keyB, v)
+	}
+	if _, ok := a.Assessment.Guarantees[gt.Name]; ok {
+		t.Errorf("Unexpected assessment of unscoped guarantee %s", gt.Name)
+	}
+}
+
+func TestEvaluateAgreementWithCompliance(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(1)}},
+	}
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	gt := &a.Details.Guarantees[0]
+	gt.Objective = &model.Objective{Target: 0.5}
+
+	result, err := EvaluateAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	updateAssessment(&a, result, t0)
+
+	/* already accounted points are skipped */
+	values = append(values, assessment_model.ExpressionData{
+		"m": model.MetricValue{Key: "m", Value: 2, DateTime: t_(2)},
+	})
+	result, _ = EvaluateAgreement(context.Background(), &a, simpleadapter.New(values), t0, nil)
+	updateAssessment(&a, result, t0)
+
+	compliance := a.Assessment.GetGuarantee(gt.Name).Compliance
+	if compliance == nil || compliance.Current == nil {
+		t.Fatalf("Compliance not set in assessment")
+	}
+	p := compliance.Current
+	if p.Points != 3 || p.FailedPoints != 1 || p.ErrorBudget == nil {
+		t.Errorf("Unexpected compliance: %v", *p)
+	}
+}
+
+func TestAssessAgreementStatus(t *testing.T) {
+	a := createAgreementFull("a_status", p1, c2, "Agreement with status",
+		map[string]string{"gt1": "m >= 0", "gt2": "m >= -10"}, nil)
+	a.State = model.STARTED
+	a.Details.Guarantees[0].Warning = "m >= 5"
+	a.Details.Guarantees[1].Warning = "m >= 5"
+	assess := func(now time.Time, values ...float64) {
+		data := assessment_model.GuaranteeData{}
+		for i, v := range values {
+			data = append(data, assessment_model.ExpressionData{
+				"m": model.MetricValue{Key: "m", Value: v, DateTime: now.Add(time.Duration(i-len(values)) * time.Second)},
+			})
+		}
+		AssessAgreement(context.Background(), &a, simpleadapter.New(data), now, nil)
+	}
+	check := func(gt1, gt2, agreement model.Status) {
+		t.Helper()
+		if s := a.Assessment.GetGuarantee("gt1").Status; s != gt1 {
+			t.Errorf("Unexpected status of gt1. Expected: %s. Actual: %s", gt1, s)
+		}
+		if s := a.Assessment.GetGuarantee("gt2").Status; s != gt2 {
+			t.Errorf("Unexpected status of gt2. Expected: %s. Actual: %s", gt2, s)
+		}
+		if s := a.Assessment.Status; s != agreement {
+			t.Errorf("Unexpected status of agreement. Expected: %s. Actual: %s", agreement, s)
+		}
+	}
+
+	assess(t0)
+	check(model.UNKNOWN, model.UNKNOWN, model.UNKNOWN)
+
+	assess(t_(10), -1, 10)
+	check(model.FULFILLED, model.FULFILLED, model.FULFILLED)
+	if change := a.Assessment.StatusChange; change == nil || !change.Equal(t_(10)) {
+		t.Errorf("Unexpected status change: %v", change)
+	}
+
+	assess(t_(20), 1)
+	check(model.WARNING, model.WARNING, model.WARNING)
+
+	assess(t_(30), -1)
+	check(model.VIOLATED, model.WARNING, model.VIOLATED)
+	if change := a.Assessment.GetGuarantee("gt2").StatusChange; change == nil || !change.Equal(t_(20)) {
+		t.Errorf("Unexpected status change of gt2: %v", change)
+	}
+
+	/* without values, the status is kept */
+	assess(t_(40))
+	check(model.VIOLATED, model.WARNING, model.VIOLATED)
+}
+
+func TestEvaluateAgreementWithNoData(t *testing.T) {
+	a := createAgreementFull("a_nodata", p1, c2, "Agreement without data",
+		map[string]string{"ignored": "m >= 0", "unknown": "m >= 0", "violation": "m >= 0"}, nil)
+	a.State = model.STARTED
+	for i := range a.Details.Guarantees {
+		gt := &a.Details.Guarantees[i]
+		if gt.Name != "ignored" {
+			gt.OnNoData = model.NoDataPolicy(gt.Name)
+		}
+	}
+	empty := simpleadapter.New(assessment_model.GuaranteeData{})
+
+	result := AssessAgreement(context.Background(), &a, empty, t0, nil)
+	if len(result.NoData) != 2 {
+		t.Errorf("Expected 2 terms without data. Actual: %v", result.NoData)
+	}
+	if _, ok := result.NoData["ignored"]; ok {
+		t.Errorf("Term with ignore policy should not be without data")
+	}
+	violations := result.Violated["violation"].Violations
+	if len(result.Violated) != 1 || len(violations) != 1 || !violations[0].NoData {
+		t.Errorf("Expected a no data violation. Actual: %v", result.Violated)
+	}
+	if since := a.Assessment.GetGuarantee("unknown").NoDataSince; since == nil || !since.Equal(t0) {
+		t.Errorf("Unexpected NoDataSince: %v", since)
+	}
+
+	/* the violation is raised only when data stops */
+	result = AssessAgreement(context.Background(), &a, empty, t_(60), nil)
+	if len(result.Violated) != 0 {
+		t.Errorf("Unexpected violations: %v", result.Violated)
+	}
+	if since := result.NoData["violation"]; !since.Equal(t0) {
+		t.Errorf("Unexpected time without data. Expected: %v. Actual: %v", t0, since)
+	}
+
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(100)}},
+	}
+	result = AssessAgreement(context.Background(), &a, simpleadapter.New(values), t_(120), nil)
+	if len(result.NoData) != 0 {
+		t.Errorf("Unexpected terms without data: %v", result.NoData)
+	}
+	if recovered := result.Recovered["violation"]; len(recovered) != 1 || !recovered[0].Equal(t_(100)) {
+		t.Errorf("Unexpected recoveries: %v", result.Recovered)
+	}
+	if since := a.Assessment.GetGuarantee("unknown").NoDataSince; since != nil {
+		t.Errorf("NoDataSince not cleared: %v", since)
+	}
+}
+
+func TestEvaluateAgreementWithStaleData(t *testing.T) {
+	a := createAgreement("a_stale", p1, c2, "Agreement with stale data", "m >= 0")
+	a.State = model.STARTED
+	a.Details.Guarantees[0].OnNoData = model.NoDataUnknown
+	a.Details.Guarantees[0].Staleness = 60
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(0)}},
+	}
+
+	result := AssessAgreement(context.Background(), &a, simpleadapter.New(values), t_(90), nil)
+	if _, ok := result.NoData["TestGuarantee"]; !ok {
+		t.Errorf("Expected stale data. Result: %v", result)
+	}
+
+	empty := simpleadapter.New(assessment_model.GuaranteeData{})
+	a.Assessment = model.Assessment{}
+	AssessAgreement(context.Background(), &a, simpleadapter.New(values), t_(30), nil)
+	result = AssessAgreement(context.Background(), &a, empty, t_(50), nil)
+	if _, ok := result.NoData["TestGuarantee"]; ok {
+		t.Errorf("Last values are not stale. Result: %v", result)
 	}
 }
 
@@ -266,10 +1270,11 @@ func TestEvaluateGuarantee(t *testing.T) {
 		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(1)}},
 	}
 	ma := simpleadapter.New(values)
-	invalid, last, err := EvaluateGuarantee(&a1, a1.Details.Guarantees[0], ma, time.Now())
+	ev, err := EvaluateGuarantee(context.Background(), &a1, a1.Details.Guarantees[0], ma, time.Now(), nil)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
+	invalid, last := ev.Failed, ev.Last
 	if len(invalid) != 1 {
 		t.Errorf("Number of invalid metrics. Expected %d. Actual: %d", 1, len(invalid))
 		return
@@ -283,29 +1288,225 @@ func TestEvaluateGuarantee(t *testing.T) {
 	}
 }
 
+func TestEvaluateGuaranteeWithFunctions(t *testing.T) {
+	// 2018-01-07 is a Sunday
+	sunday := time.Date(2018, 1, 7, 12, 0, 0, 0, time.UTC)
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1.0, DateTime: sunday}},
+		{"m": model.MetricValue{Key: "m", Value: -1.0, DateTime: sunday.Add(24 * time.Hour)}},
+		{"m": model.MetricValue{Key: "m", Value: 20.0, DateTime: sunday.Add(48 * time.Hour)}},
+	}
+	a := createAgreement("a01", p1, c2, "Agreement 01", "between(m, 0, 10) || weekday() == 0")
+	ev, err := EvaluateGuarantee(context.Background(), &a, a.Details.Guarantees[0], simpleadapter.New(values), time.Now(), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(ev.Failed) != 2 || ev.Failed[0]["m"].Value != -1.0 || ev.Failed[1]["m"].Value != 20.0 {
+		t.Errorf("Unexpected failed values. Expected: [m=-1, m=20]. Actual: %v", ev.Failed)
+	}
+}
+
 func TestEvaluateGuaranteeWithWrongExpression(t *testing.T) {
 	ma := simpleadapter.New(nil)
 	a := createAgreement("a01", p1, c2, "Agreement 01", "wrong expression >= 0")
-	_, _, err := EvaluateGuarantee(&a, a.Details.Guarantees[0], ma, time.Now())
+	_, err := EvaluateGuarantee(context.Background(), &a, a.Details.Guarantees[0], ma, time.Now(), nil)
 	if err == nil {
 		t.Errorf("Expected error evaluating guarantee")
 	}
 }
 
+func TestEvaluateGuaranteeWithNonBooleanExpression(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1.0, DateTime: t_(0)}},
+	}
+	a := createAgreement("a01", p1, c2, "Agreement 01", "abs(m)")
+	_, err := EvaluateGuarantee(context.Background(), &a, a.Details.Guarantees[0], simpleadapter.New(values), time.Now(), nil)
+	if err == nil {
+		t.Errorf("Expected error evaluating non boolean expression")
+	}
+}
+
 func TestEvaluateGuaranteeWithWrongValues(t *testing.T) {
 	values := assessment_model.GuaranteeData{
 		{"n": model.MetricValue{Key: "n", Value: 1, DateTime: t_(0)}},
 	}
 	ma := simpleadapter.New(values)
-	_, _, err := EvaluateGuarantee(&a1, a1.Details.Guarantees[0], ma, time.Now())
+	_, err := EvaluateGuarantee(context.Background(), &a1, a1.Details.Guarantees[0], ma, time.Now(), nil)
+	if err == nil {
+		t.Errorf("Expected error evaluating guarantee")
+	}
+}
+
+func TestEvaluateGuaranteeWithMaintenance(t *testing.T) {
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	gt := &a.Details.Guarantees[0]
+	gt.Tolerance = &model.Tolerance{Points: 2}
+	windows := model.MaintenanceWindows{
+		{Id: "w_skip", AgreementId: a.Id, Start: t_(0), End: t_(1)},
+		{Id: "w_tag", AgreementId: a.Id, Start: t_(3), End: t_(4), Action: model.MaintenanceTag},
+	}
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(3)}},
+	}
+	ma := simpleadapter.New(values)
+
+	ev, err := EvaluateGuarantee(context.Background(), &a, *gt, ma, t_(4), windows)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ev.Failing == nil || ev.Failing.Points != 1 || ev.Failing.Violated || ev.Status != model.WARNING {
+		t.Errorf("Points in maintenance must not count in the failing streak. Streak: %v. Status: %v",
+			ev.Failing, ev.Status)
+	}
+	if c := ev.Compliance.Current; c == nil || c.Points != 1 || c.FailedPoints != 1 {
+		t.Errorf("Points in maintenance must not count in the compliance: %v", c)
+	}
+	if len(ev.Violated) != 1 || !tupleTime(ev.Violated[0]).Equal(t_(3)) {
+		t.Errorf("Expected the failing point in the tagging window as violated. Actual: %v", ev.Violated)
+	}
+
+	result, _ := EvaluateAgreement(context.Background(), &a, ma, t_(4), windows)
+	violations := result.Violated[gt.Name].Violations
+	if len(violations) != 1 || violations[0].Maintenance != "w_tag" {
+		t.Errorf("Unexpected violations: %v", violations)
+	}
+}
+
+func TestEvaluateGuaranteeWithCancelledContext(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 1, DateTime: t_(0)}},
+	}
+	ma := simpleadapter.New(values)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := EvaluateGuarantee(ctx, &a1, a1.Details.Guarantees[0], ma, time.Now(), nil)
+	if !monitor.IsRetrievalError(err) {
+		t.Errorf("Expected retrieval error. Actual: %v", err)
+	}
+}
+
+func TestEvaluateGuaranteeWithWarning(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 15, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: 5, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(2)}},
+	}
+	ma := simpleadapter.New(values)
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	a.Details.Guarantees[0].Warning = "m >= 10"
+
+	ev, err := EvaluateGuarantee(context.Background(), &a, a.Details.Guarantees[0], ma, time.Now(), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(ev.Failed) != 1 || ev.Failed[0]["m"].Value != -1 {
+		t.Errorf("Unexpected failed metrics. Expected: [m=-1]. Actual: %v", ev.Failed)
+	}
+	if len(ev.Warned) != 1 || ev.Warned[0]["m"].Value != 5 {
+		t.Errorf("Unexpected warned metrics. Expected: [m=5]. Actual: %v", ev.Warned)
+	}
+
+	result, err := EvaluateAgreement(context.Background(), &a, ma, time.Now(), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(result.Warned[a.Details.Guarantees[0].Name]) != 1 {
+		t.Errorf("Unexpected warned guarantees: %v", result.Warned)
+	}
+}
+
+func TestEvaluateGuaranteeWithWrongWarning(t *testing.T) {
+	ma := simpleadapter.New(nil)
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0")
+	a.Details.Guarantees[0].Warning = "wrong expression >= 0"
+	_, err := EvaluateGuarantee(context.Background(), &a, a.Details.Guarantees[0], ma, time.Now(), nil)
 	if err == nil {
 		t.Errorf("Expected error evaluating guarantee")
 	}
 }
 
+func TestMergeVars(t *testing.T) {
+	vars := mergeVars([]string{"a", "b"}, []string{"b", "c", "c"})
+	if len(vars) != 3 || vars[0] != "a" || vars[1] != "b" || vars[2] != "c" {
+		t.Errorf("Unexpected merged vars. Expected: [a b c]. Actual: %v", vars)
+	}
+}
+
+func TestEvaluateGtPenalties(t *testing.T) {
+	gt := model.Guarantee{
+		Name:       "gt",
+		Constraint: "m >= 0",
+		Penalties: []model.PenaltyDef{
+			{Type: "discount", Value: "10", Unit: "%"},
+			{Type: "service", Value: "1", Unit: "month"},
+		},
+	}
+	v := model.Violation{Id: "v", AgreementId: "a", Guarantee: gt.Name, Datetime: t0}
+
+	penalties := EvaluateGtPenalties(gt, v)
+	if len(penalties) != len(gt.Penalties) {
+		t.Fatalf("Unexpected number of penalties. Expected: %d. Actual: %d", len(gt.Penalties), len(penalties))
+	}
+	validater := model.NewDefaultValidator(true, true)
+	for i, p := range penalties {
+		if errs := p.Validate(validater, model.CREATE); len(errs) != 0 {
+			t.Errorf("Validation error in penalty: %v", errs)
+		}
+		if p.ViolationId != v.Id || !p.Datetime.Equal(v.Datetime) || p.Definition != gt.Penalties[i] {
+			t.Errorf("Unexpected penalty: %v", p)
+		}
+	}
+
+	gt.Penalties = nil
+	if penalties = EvaluateGtPenalties(gt, v); len(penalties) != 0 {
+		t.Errorf("Unexpected number of penalties. Expected: 0. Actual: %d", len(penalties))
+	}
+}
+
+func TestBuildRetrievalItems(t *testing.T) {
+	a := createAgreement("a01", p1, c2, "Agreement 01", "m >= 0 && n >= 0 && o >= 0")
+	a.Details.Variables = []model.Variable{
+		{Name: "m", Metric: "m"},
+		{Name: "n", Metric: "n", Aggregation: &model.Aggregation{Type: model.AVERAGE, Window: 3600}},
+		{Name: "o", Metric: "o", Aggregation: &model.Aggregation{Type: model.AVERAGE,
+			Calendar: &model.Calendar{Period: model.MONTH}}},
+	}
+	a.Assessment.LastExecution = time.Date(2019, 3, 15, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2019, 3, 15, 10, 0, 0, 0, time.UTC)
+
+	expected := map[string]time.Time{
+		"m": a.Assessment.LastExecution,
+		"n": to.Add(-time.Hour),
+		"o": time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	items := BuildRetrievalItems(&a, a.Details.Guarantees[0], []string{"m", "n", "o"}, to)
+	if len(items) != len(expected) {
+		t.Fatalf("Unexpected number of items. Expected: %d. Actual: %d", len(expected), len(items))
+	}
+	for _, item := range items {
+		if !item.From.Equal(expected[item.Var.Name]) || !item.To.Equal(to) {
+			t.Errorf("Unexpected interval for %s. Expected: %v - %v. Actual: %v - %v",
+				item.Var.Name, expected[item.Var.Name], to, item.From, item.To)
+		}
+		if item.Member != "" {
+			t.Errorf("Unexpected member of unscoped item %s: %s", item.Var.Name, item.Member)
+		}
+	}
+
+	gt := a.Details.Guarantees[0]
+	gt.Scope = model.Scope{"host-a"}
+	for _, item := range BuildRetrievalItems(&a, gt, []string{"m", "n"}, to) {
+		if item.Member != "host-a" {
+			t.Errorf("Unexpected member of item %s: %s", item.Var.Name, item.Member)
+		}
+	}
+}
+
 func TestEvaluateExpression(t *testing.T) {
 	c := "m >= 0"
-	expression, err := govaluate.NewEvaluableExpression(c)
+	expression, err := model.NewExpression(c)
 	if err != nil {
 		t.Errorf("Error parsing expression '%s': %s", c, err.Error())
 	}
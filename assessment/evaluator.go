@@ -22,9 +22,12 @@ import (
 	"SLALite/assessment/monitor"
 	"SLALite/assessment/notifier"
 	"SLALite/model"
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/Knetic/govaluate"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -32,37 +35,398 @@ func init() {
 	log.SetLevel(log.DebugLevel)
 }
 
+// Config sets how AssessActiveAgreements assesses the active agreements
+type Config struct {
+	// Workers is the number of agreements assessed concurrently. A value lower
+	// than 1 is taken as 1.
+	Workers int
+	// Timeout is the maximum duration of the assessment of an agreement.
+	// An agreement whose assessment overruns is marked as TimedOut and its
+	// results are discarded. Zero means no timeout.
+	Timeout time.Duration
+	// Period is how often a Scheduler assesses the agreements without a Schedule
+	// in their details. A value lower than a second is taken as DefaultPeriod.
+	Period time.Duration
+	// ExternalIDs is true when the ids of the violations, penalties and incidents
+	// are set by the repository (see model.NewDefaultValidator)
+	ExternalIDs bool
+}
+
+// assessed is the outcome of the assessment of an agreement by a worker. Skipped is
+// set if the agreement was not assessed because it was already being assessed.
+type assessed struct {
+	agreement model.Agreement
+	result    amodel.Result
+	skipped   bool
+}
+
+// inFlight is the set of the ids of the agreements being assessed, so that an
+// agreement is not assessed again while a previous assessment is still running
+// (see assessWithTimeout)
+type inFlight struct {
+	sync.Mutex
+	ids map[string]bool
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{ids: map[string]bool{}}
+}
+
+// add adds an agreement to the set. Returns false if it was already in the set.
+func (f *inFlight) add(id string) bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.ids[id] {
+		return false
+	}
+	f.ids[id] = true
+	return true
+}
+
+// remove removes an agreement from the set
+func (f *inFlight) remove(id string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.ids, id)
+}
+
 //AssessActiveAgreements will get the active agreements from the provided repository and assess them, notifying about violations with the provided notifier.
-func AssessActiveAgreements(repo model.IRepository, ma monitor.MonitoringAdapter, not notifier.ViolationNotifier) {
+//
+// Agreements are assessed by a pool of cfg.Workers workers; ctx is passed on to the
+// monitoring adapter, so that cancelling it cancels the pending retrievals. The results are persisted
+// and notified sequentially, so the notifier does not need to be thread-safe; the
+// monitoring adapter may be called concurrently. The repository must be thread-safe
+// if it is used at the same time by others, e.g., by the re-assessments of a Scheduler.
+//
+// The goroutines of the assessments that timed out are only tracked during the call;
+// use a Scheduler to skip the agreements whose previous assessment is still running.
+func AssessActiveAgreements(ctx context.Context, repo model.IRepository, ma monitor.MonitoringAdapter, not notifier.ViolationNotifier, cfg Config) {
 	agreements, err := repo.GetAgreementsByState(model.STARTED, model.STOPPED)
 	if err != nil {
 		log.Errorf("Error getting active agreements: %s", err.Error())
-	} else {
-		log.Printf("AssessActiveAgreements(). %d agreements to evaluate", len(agreements))
+		return
+	}
+	log.Printf("AssessActiveAgreements(). %d agreements to evaluate", len(agreements))
+	assessAgreements(ctx, repo, ma, not, cfg, agreements, newInFlight())
+}
+
+// assessAgreements assesses the agreements with a pool of workers, and persists
+// and notifies the results, as described in AssessActiveAgreements. The agreements
+// in running are skipped; they are neither persisted nor notified. The violations
+// tagged with a maintenance window are persisted, but they neither open incidents
+// nor are notified (see withoutTagged).
+func assessAgreements(ctx context.Context, repo model.IRepository, ma monitor.MonitoringAdapter,
+	not notifier.ViolationNotifier, cfg Config, agreements model.Agreements, running *inFlight) {
+
+	windows, err := repo.GetMaintenanceWindows(model.MaintenanceWindowQuery{})
+	if err != nil {
+		log.Errorf("Error getting maintenance windows: %s", err.Error())
+	}
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan model.Agreement)
+	results := make(chan assessed)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for agreement := range jobs {
+				results <- assessWithTimeout(ctx, agreement, ma, time.Now(), windows, cfg.Timeout, running)
+			}
+		}()
+	}
+	go func() {
 		for _, agreement := range agreements {
-			result := AssessAgreement(&agreement, ma, time.Now())
-			repo.UpdateAgreement(&agreement)
-			if not != nil && len(result.Violated) > 0 {
-				not.NotifyViolations(&agreement, &result)
+			jobs <- copyAgreement(agreement)
+		}
+		close(jobs)
+	}()
+
+	for range agreements {
+		r := <-results
+		if r.skipped {
+			continue
+		}
+		agreement, result := r.agreement, r.result
+		persistViolations(repo, &agreement, &result, cfg.ExternalIDs)
+		result = withoutTagged(result)
+		recovered := persistIncidents(repo, &agreement, &result, cfg.ExternalIDs)
+		repo.UpdateAgreement(&agreement)
+		if not != nil && len(result.Violated) > 0 {
+			not.NotifyViolations(&agreement, &result)
+		}
+		if wn, ok := not.(notifier.WarningNotifier); ok && len(result.Warned) > 0 {
+			wn.NotifyWarnings(&agreement, &result)
+		}
+		if rn, ok := not.(notifier.RecoveryNotifier); ok && len(recovered) > 0 {
+			rn.NotifyRecoveries(&agreement, recovered)
+		}
+	}
+}
+
+// assessWithTimeout assesses an agreement, giving up after timeout (if not zero).
+//
+// On timeout, the returned agreement is the input agreement marked as TimedOut and the
+// result is empty. The context passed to the assessment is cancelled, but adapters that
+// are not context-aware are not interrupted: the assessment goes on in its goroutine,
+// and its outcome is discarded. The agreement is in running until its assessment
+// finishes, so an agreement already in running is not assessed and it is returned
+// as skipped. This bounds the number of these goroutines to one per agreement.
+func assessWithTimeout(ctx context.Context, a model.Agreement, ma monitor.MonitoringAdapter,
+	now time.Time, windows model.MaintenanceWindows, timeout time.Duration, running *inFlight) assessed {
+
+	if !running.add(a.Id) {
+		log.Warnf("Skipping assessment of agreement %s: it is already being assessed", a.Id)
+		return assessed{agreement: a, skipped: true}
+	}
+	assess := func(ctx context.Context, a model.Agreement) assessed {
+		defer running.remove(a.Id)
+		result := AssessAgreement(ctx, &a, ma, now, windows)
+		a.Assessment.TimedOut = false
+		return assessed{agreement: a, result: result}
+	}
+	if timeout <= 0 {
+		return assess(ctx, a)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan assessed, 1)
+	go func(work model.Agreement) {
+		done <- assess(ctx, work)
+	}(copyAgreement(a))
+
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		log.Warnf("Assessment of agreement %s timed out after %v", a.Id, timeout)
+		a.Assessment.TimedOut = true
+		return assessed{agreement: a}
+	}
+}
+
+// copyAgreement returns a copy of an agreement whose assessment info does not share
+// maps with the original, so that it can be modified from a different goroutine.
+func copyAgreement(a model.Agreement) model.Agreement {
+	if a.Assessment.Guarantees == nil {
+		return a
+	}
+	guarantees := make(map[string]model.AssessmentGuarantee, len(a.Assessment.Guarantees))
+	for name, ag := range a.Assessment.Guarantees {
+		lastValues := make(model.LastValues, len(ag.LastValues))
+		for k, v := range ag.LastValues {
+			lastValues[k] = v
+		}
+		ag.LastValues = lastValues
+		if ag.Failing != nil {
+			failing := *ag.Failing
+			ag.Failing = &failing
+		}
+		if ag.Compliance != nil {
+			ag.Compliance = ag.Compliance.Copy()
+		}
+		guarantees[name] = ag
+	}
+	a.Assessment.Guarantees = guarantees
+	return a
+}
+
+// persistViolations stores in repository the violations contained in result,
+// and the penalties raised by each violation. The violations tagged with a
+// maintenance window do not raise penalties.
+//
+// An id is generated for each violation, unless externalIDs is true; the
+// violations in result are updated with the id of the stored violation, so
+// that notifiers can reference them.
+func persistViolations(repo model.IRepository, a *model.Agreement, result *amodel.Result, externalIDs bool) {
+	for _, gtresult := range result.Violated {
+		for i := range gtresult.Violations {
+			v := &gtresult.Violations[i]
+			v.Id = newID(externalIDs)
+			created, err := repo.CreateViolation(v)
+			if err != nil {
+				log.Errorf("Error persisting violation of agreement %s, guarantee %s: %s",
+					v.AgreementId, v.Guarantee, err.Error())
+				continue
 			}
+			v.Id = created.Id
+			if v.Maintenance != "" {
+				continue
+			}
+			gt, _ := a.Details.GetGuarantee(v.Guarantee)
+			persistPenalties(repo, EvaluateGtPenalties(gt, *v), externalIDs)
+		}
+	}
+}
+
+// withoutTagged returns a copy of result without the violations tagged with a
+// maintenance window, nor their metrics. The failing points during a window do not
+// count in the failing streak, so a violation that opened an incident in a window
+// would never be recovered.
+func withoutTagged(result amodel.Result) amodel.Result {
+	violated := make(map[string]amodel.EvaluationGtResult, len(result.Violated))
+	for key, gtresult := range result.Violated {
+		tagged := make(map[int64]bool)
+		violations := make([]model.Violation, 0, len(gtresult.Violations))
+		for _, v := range gtresult.Violations {
+			if v.Maintenance != "" {
+				tagged[v.Datetime.UnixNano()] = true
+				continue
+			}
+			violations = append(violations, v)
+		}
+		if len(violations) == 0 {
+			continue
+		}
+		metrics := make(amodel.GuaranteeData, 0, len(gtresult.Metrics))
+		for _, tuple := range gtresult.Metrics {
+			if !tagged[tupleTime(tuple).UnixNano()] {
+				metrics = append(metrics, tuple)
+			}
+		}
+		violated[key] = amodel.EvaluationGtResult{Metrics: metrics, Violations: violations}
+	}
+	result.Violated = violated
+	return result
+}
+
+// persistIncidents opens, updates and closes the incidents of the guarantee terms of
+// an agreement, according to the violations and recoveries in result. The violations
+// must have been persisted. The id of the open incident of each term is kept in the
+// agreement assessment.
+//
+// Returns the incidents closed by a recovery.
+func persistIncidents(repo model.IRepository, a *model.Agreement, result *amodel.Result, externalIDs bool) []model.Incident {
+	closed := make([]model.Incident, 0)
+
+	for _, gt := range guaranteeMembers(a) {
+		key := gt.AssessmentKey()
+		violations := result.Violated[key].Violations
+		recoveries := result.Recovered[key]
+		if len(violations) == 0 && len(recoveries) == 0 {
+			continue
+		}
+		ag := a.Assessment.GetGuarantee(key)
+
+		var incident *model.Incident
+		stored := false
+		if ag.Incident != "" {
+			i, err := repo.GetIncident(ag.Incident)
+			if err != nil {
+				log.Errorf("Error getting incident %s of agreement %s: %s", ag.Incident, a.Id, err.Error())
+			} else {
+				incident, stored = i, true
+			}
+		}
+
+		vi, ri := 0, 0
+		for vi < len(violations) || ri < len(recoveries) {
+			if ri == len(recoveries) || vi < len(violations) && violations[vi].Datetime.Before(recoveries[ri]) {
+				v := violations[vi]
+				vi++
+				if incident == nil {
+					incident = &model.Incident{
+						Id:          newID(externalIDs),
+						AgreementId: a.Id,
+						Guarantee:   gt.Name,
+						Start:       v.Datetime,
+						Violations:  []string{},
+						Member:      gt.Member(),
+					}
+					stored = false
+				}
+				incident.Violations = append(incident.Violations, v.Id)
+				continue
+			}
+			end := recoveries[ri]
+			ri++
+			if incident == nil {
+				continue
+			}
+			incident.Close(end)
+			saveIncident(repo, incident, stored)
+			closed = append(closed, *incident)
+			incident = nil
+		}
+
+		ag.Incident = ""
+		if incident != nil {
+			saveIncident(repo, incident, stored)
+			ag.Incident = incident.Id
+		}
+		a.Assessment.SetGuarantee(key, ag)
+	}
+	return closed
+}
+
+// guaranteeMembers returns the guarantee terms of an agreement expanded by scope member
+// (see model.Guarantee.Members)
+func guaranteeMembers(a *model.Agreement) []model.Guarantee {
+	result := make([]model.Guarantee, 0, len(a.Details.Guarantees))
+	for _, gt := range a.Details.Guarantees {
+		result = append(result, gt.Members()...)
+	}
+	return result
+}
+
+// saveIncident creates or updates an incident, depending on it being already stored.
+// A created incident is updated with the id of the stored incident.
+func saveIncident(repo model.IRepository, incident *model.Incident, stored bool) {
+	var err error
+	if stored {
+		_, err = repo.UpdateIncident(incident)
+	} else {
+		var created *model.Incident
+		if created, err = repo.CreateIncident(incident); err == nil {
+			incident.Id = created.Id
+		}
+	}
+	if err != nil {
+		log.Errorf("Error persisting incident %s of agreement %s, guarantee %s: %s",
+			incident.Id, incident.AgreementId, incident.Guarantee, err.Error())
+	}
+}
+
+func persistPenalties(repo model.IRepository, penalties []model.Penalty, externalIDs bool) {
+	for i := range penalties {
+		p := &penalties[i]
+		p.Id = newID(externalIDs)
+		if _, err := repo.CreatePenalty(p); err != nil {
+			log.Errorf("Error persisting penalty of violation %s: %s", p.ViolationId, err.Error())
 		}
 	}
 }
 
+// newID returns a new id for an entity to be created in the repository, or an empty
+// id if externalIDs is true (i.e., the ids are set by the repository)
+func newID(externalIDs bool) string {
+	if externalIDs {
+		return ""
+	}
+	return uuid.New().String()
+}
+
 // AssessAgreement is the process that assess an agreement. The process is:
 // 1. Check expiration date
-// 2. Evaluate metrics if agreement is started
-// 3. Set LastExecution time.
+// 2. Evaluate metrics if agreement is started, applying the maintenance windows
+// 3. Set LastExecution time and the status of the guarantee terms and the agreement.
+//
+// The guarantee terms that could not be evaluated keep their LastExecution time, so that
+// their values are evaluated again in the next assessment, and the error is stored in
+// their assessment information.
 //
 // The output is:
 // - parameter a is modified
-// - evaluation results are the function return (violated metrics and raised violations).
-//   a guarantee term is filled in the result only if there are violations.
+// - evaluation results are the function return (violated metrics and raised violations,
+//   and metrics that breached the warning threshold).
+//   a guarantee term is filled in the result only if there are violations or warnings.
 //
 // The function results are not persisted. The output must be persisted/handled accordingly.
 // E.g.: agreement and violations must be persisted to DB. Violations must be notified to
 // observers
-func AssessAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now time.Time) amodel.Result {
+func AssessAgreement(ctx context.Context, a *model.Agreement, ma monitor.MonitoringAdapter, now time.Time,
+	windows model.MaintenanceWindows) amodel.Result {
 	var result amodel.Result
 	var err error
 
@@ -73,7 +437,7 @@ func AssessAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now time.
 	}
 
 	if a.State == model.STARTED {
-		result, err = EvaluateAgreement(a, ma, now)
+		result, err = EvaluateAgreement(ctx, a, ma, now, windows)
 		if err != nil {
 			log.Warn("Error evaluating agreement " + a.Id + ": " + err.Error())
 			return result
@@ -83,64 +447,234 @@ func AssessAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now time.
 	return result
 }
 
+// ReassessAgreement re-runs the assessment of an agreement over the past interval
+// [from, to], calling AssessAgreement when the agreement is due after from (every
+// Schedule of the agreement or, if not set, every cfg.Period) and at to, as if the
+// periodic assessment had run then. A non-positive period assesses the interval at
+// once. The ids of the violations are set as in the periodic assessment, according
+// to cfg.ExternalIDs.
+//
+// The assessment starts from a clean state at from, regardless of the agreement
+// state; the re-assessment stops at the expiration date. The input agreement is not
+// modified. The raised violations and their penalties are persisted, marked as
+// Backfilled; the violations that were already stored in [from, to] (by the periodic
+// assessment or by a previous re-assessment) are kept and not raised again. The
+// maintenance windows in the repository are applied. Nothing is notified, and
+// neither the incidents nor the assessment of the agreement are updated.
+//
+// Returns the persisted violations. If ctx is cancelled, the re-assessment stops and
+// the violations persisted so far are returned along with the context error.
+func ReassessAgreement(ctx context.Context, repo model.IRepository, a model.Agreement,
+	ma monitor.MonitoringAdapter, from, to time.Time, cfg Config) ([]model.Violation, error) {
+
+	log.Debugf("ReassessAgreement(%s, %v, %v)", a.Id, from, to)
+	windows, err := repo.GetMaintenanceWindows(model.MaintenanceWindowQuery{})
+	if err != nil {
+		return nil, err
+	}
+	stored, err := storedViolations(repo, a.Id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	a.State = model.STARTED
+	a.Assessment = model.Assessment{
+		FirstExecution: from,
+		LastExecution:  from,
+	}
+	violations := make([]model.Violation, 0)
+	for now := from; now.Before(to) && a.State == model.STARTED; {
+		if err := ctx.Err(); err != nil {
+			return violations, err
+		}
+		now = nextReassessment(a, now, to, cfg.Period)
+		result := AssessAgreement(ctx, &a, ma, now, windows)
+		for key, gtresult := range result.Violated {
+			raised := gtresult.Violations[:0]
+			for _, v := range gtresult.Violations {
+				if stored[violationKey(v)] {
+					continue
+				}
+				v.Backfilled = true
+				raised = append(raised, v)
+			}
+			gtresult.Violations = raised
+			result.Violated[key] = gtresult
+		}
+		persistViolations(repo, &a, &result, cfg.ExternalIDs)
+		violations = append(violations, result.GetViolations()...)
+	}
+	return violations, nil
+}
+
+// nextReassessment returns the time of the assessment that follows the one at now
+// in a re-assessment up to "to" (see ReassessAgreement)
+func nextReassessment(a model.Agreement, now, to time.Time, period time.Duration) time.Time {
+	next := nextAssessment(a, now, period)
+	if !next.After(now) || next.After(to) {
+		next = to
+	}
+	return next
+}
+
+// storedViolations returns the keys (see violationKey) of the violations of an
+// agreement stored in [from, to]
+func storedViolations(repo model.IRepository, agreementID string, from, to time.Time) (map[string]bool, error) {
+	violations, err := repo.GetViolations(model.ViolationQuery{
+		AgreementId: agreementID,
+		From:        from,
+		To:          to.Add(time.Millisecond),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(violations))
+	for _, v := range violations {
+		result[violationKey(v)] = true
+	}
+	return result, nil
+}
+
+// violationKey identifies the violation of a guarantee term (or of a scope member
+// of the term) at a time. The time is truncated to milliseconds, the precision of
+// the times stored in MongoDB.
+func violationKey(v model.Violation) string {
+	return fmt.Sprintf("%s@%d", model.MemberKey(v.Guarantee, v.Member),
+		v.Datetime.Truncate(time.Millisecond).UnixNano())
+}
+
 func updateAssessment(a *model.Agreement, result amodel.Result, now time.Time) {
 	if a.Assessment.FirstExecution.IsZero() {
 		a.Assessment.FirstExecution = now
 	}
 	a.Assessment.LastExecution = now
 
-	for gtname, last := range result.LastValues {
-		updateAssessmentGuarantee(a, gtname, last, now)
+	for key := range result.LastValues {
+		updateAssessmentGuarantee(a, key, result, now)
+	}
+	for key, e := range result.Errors {
+		ag := a.Assessment.GetGuarantee(key)
+		evErr := e
+		ag.Error = &evErr
+		ag.SetStatus(model.UNKNOWN, now)
+		a.Assessment.SetGuarantee(key, ag)
+	}
+
+	var status model.Status
+	for _, gt := range guaranteeMembers(a) {
+		if s := a.Assessment.GetGuarantee(gt.AssessmentKey()).Status; s.Worse(status) {
+			status = s
+		}
+	}
+	if status != "" {
+		a.Assessment.SetStatus(status, now)
 	}
 }
 
-func updateAssessmentGuarantee(a *model.Agreement, gtname string, last amodel.ExpressionData, now time.Time) {
-	ag := a.Assessment.GetGuarantee(gtname)
+func updateAssessmentGuarantee(a *model.Agreement, key string, result amodel.Result, now time.Time) {
+	ag := a.Assessment.GetGuarantee(key)
 	ag.LastExecution = now
 	if ag.FirstExecution.IsZero() {
 		ag.FirstExecution = now
 	}
-	for _, v := range last {
+	for _, v := range result.LastValues[key] {
 		ag.LastValues[v.Key] = v
 	}
-	a.Assessment.SetGuarantee(gtname, ag)
+	ag.Failing = result.Failing[key]
+	if compliance := result.Compliance[key]; compliance != nil {
+		ag.Compliance = compliance
+	}
+	ag.Error = nil
+	ag.NoDataSince = nil
+	if since, ok := result.NoData[key]; ok {
+		ag.NoDataSince = &since
+	}
+	if status, ok := result.Status[key]; ok {
+		ag.SetStatus(status, now)
+	}
+	a.Assessment.SetGuarantee(key, ag)
 }
 
 // EvaluateAgreement evaluates the guarantee terms of an agreement. The metric values
 // are retrieved from a MonitoringAdapter.
+// Guarantee terms with a Scope are evaluated once per scope member; the result
+// and the assessment information are keyed by model.Guarantee.AssessmentKey.
+// Guarantee terms that are not due according to their Schedule are skipped, and
+// they are not present in the result.
 // The MonitoringAdapter must feed the process correctly
 // (e.g. if the constraint of a guarantee term is of the type "A>B && C>D", the
 // MonitoringAdapter must supply pairs of values).
-func EvaluateAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now time.Time) (amodel.Result, error) {
+// If the MonitoringAdapter is a ContextMonitoringAdapter, ctx is used on retrieval.
+// Each guarantee term is evaluated independently: a term whose expression cannot be
+// evaluated or whose values cannot be retrieved is set in the Errors of the result,
+// and the rest of terms are evaluated. The evaluation is only aborted, returning
+// the error, if ctx is done.
+// The terms without fresh data are set in the NoData of the result, according to their
+// OnNoData policy; with the violation policy, a violation is raised when the data stops.
+// The violations raised during the maintenance windows of the agreement in windows are
+// discarded or tagged (see EvaluateGtViolations), and the points evaluated during them
+// do not count in the compliance, the tolerance nor the status (see EvaluateGuarantee).
+func EvaluateAgreement(ctx context.Context, a *model.Agreement, ma monitor.MonitoringAdapter, now time.Time,
+	windows model.MaintenanceWindows) (amodel.Result, error) {
 	ma = ma.Initialize(a)
 
 	log.Debugf("EvaluateAgreement(%s)", a.Id)
 	result := amodel.Result{
 		Violated:      map[string]amodel.EvaluationGtResult{},
+		Warned:        map[string]amodel.GuaranteeData{},
 		LastValues:    map[string]amodel.ExpressionData{},
+		Failing:       map[string]*model.FailingStreak{},
+		Recovered:     map[string][]time.Time{},
+		Compliance:    map[string]*model.Compliance{},
+		Errors:        map[string]model.EvaluationError{},
+		NoData:        map[string]time.Time{},
+		Status:        map[string]model.Status{},
 		LastExecution: map[string]time.Time{},
 	}
-	gts := a.Details.Guarantees
+	gts := guaranteeMembers(a)
 
 	for _, gt := range gts {
-		/*
-		 * TODO Evaluate if gt has to be evaluated according to schedule
-		 */
-		failed, lastvalues, err := EvaluateGuarantee(a, gt, ma, now)
-		if err != nil {
-			log.Warn("Error evaluating expression " + gt.Constraint + ": " + err.Error())
+		key := gt.AssessmentKey()
+		last := a.Assessment.GetGuarantee(key).LastExecution
+		if !gt.Schedule.IsDue(last, now) {
+			log.Debugf("Skipping guarantee %s of agreement %s: not due until schedule %s", key, a.Id, gt.Schedule)
+			continue
+		}
+		ev, err := EvaluateGuarantee(ctx, a, gt, ma, now, windows)
+		if err != nil && ctx.Err() != nil {
+			log.Warnf("Evaluation of agreement %s aborted on guarantee %s: %s", a.Id, key, err.Error())
 			return amodel.Result{}, err
 		}
-		if len(failed) > 0 {
-			violations := EvaluateGtViolations(a, gt, failed)
+		if err != nil {
+			log.Warnf("Error evaluating guarantee %s: %s", key, err.Error())
+			result.Errors[key] = model.EvaluationError{Message: err.Error(), Datetime: now}
+			continue
+		}
+		violations := EvaluateGtViolations(a, gt, ev.Violated, windows)
+		if ev.NoData != nil {
+			result.NoData[key] = *ev.NoData
+			if gt.OnNoData == model.NoDataViolation && a.Assessment.GetGuarantee(key).NoDataSince == nil {
+				noData := []model.Violation{noDataViolation(a, gt, now)}
+				violations = append(violations, applyMaintenance(a, noData, windows)...)
+			}
+		}
+		if len(violations) > 0 {
 			gtResult := amodel.EvaluationGtResult{
-				Metrics:    failed,
+				Metrics:    ev.Violated,
 				Violations: violations,
 			}
-			result.Violated[gt.Name] = gtResult
+			result.Violated[key] = gtResult
 		}
-		result.LastValues[gt.Name] = lastvalues
-		result.LastExecution[gt.Name] = now
+		if len(ev.Warned) > 0 {
+			result.Warned[key] = ev.Warned
+		}
+		result.LastValues[key] = ev.Last
+		result.Failing[key] = ev.Failing
+		if len(ev.Recovered) > 0 {
+			result.Recovered[key] = ev.Recovered
+		}
+		result.Compliance[key] = ev.Compliance
+		result.Status[key] = ev.Status
+		result.LastExecution[key] = now
 	}
 	return result, nil
 }
@@ -148,81 +682,309 @@ func EvaluateAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now tim
 // EvaluateGuarantee evaluates a guarantee term of an Agreement
 // (see EvaluateAgreement)
 //
-// Returns the metrics that failed the GT constraint.
-func EvaluateGuarantee(a *model.Agreement,
+// Returns the metrics that failed the GT constraint and, if the GT has a warning
+// expression, the metrics that fulfilled the constraint but failed the warning.
+//
+// The failing points are counted in streaks, starting from the streak stored in the
+// agreement assessment. Only the failing points that exceed the GT Tolerance are
+// returned as Violated. The first point that fulfills the constraint after a violated
+// streak is returned in Recovered.
+// Every evaluated point is accounted in the compliance of the term (see
+// model.Compliance).
+// The points during a maintenance window of the agreement in windows do not count:
+// they are not accounted in the compliance, and they do not change the failing
+// streak nor the status of the term. The failing points during a window that tags
+// the violations are returned in Violated, to be tagged by EvaluateGtViolations.
+// If the term has no fresh data and its OnNoData policy is not ignore, NoData is the
+// time since it has no data. With the violation policy, the first fresh point after
+// the term had no data is returned in Recovered.
+// Status is the status of the term after the last evaluated point (see model.Status);
+// without points, it is the status in the assessment, or unknown if the term has
+// never been evaluated.
+// If gt is evaluated for a scope member (see model.Guarantee.Member), the values
+// are those of the member and the assessment information is that of the member.
+// A monitor.RetrievalError is returned if the values could not be retrieved.
+func EvaluateGuarantee(ctx context.Context, a *model.Agreement,
 	gt model.Guarantee,
 	ma monitor.MonitoringAdapter,
-	now time.Time) (
-	failed []amodel.ExpressionData, last amodel.ExpressionData, err error) {
+	now time.Time,
+	windows model.MaintenanceWindows) (result amodel.GuaranteeEvaluation, err error) {
 
-	log.Debugf("EvaluateGuarantee(%s, %s)", a.Id, gt.Name)
-	failed = make(amodel.GuaranteeData, 0, 1)
+	log.Debugf("EvaluateGuarantee(%s, %s)", a.Id, gt.AssessmentKey())
+	result.Failed = make(amodel.GuaranteeData, 0, 1)
+	result.Violated = make(amodel.GuaranteeData, 0, 1)
+	result.Warned = make(amodel.GuaranteeData, 0)
+	ag := a.Assessment.GetGuarantee(gt.AssessmentKey())
+	if ag.Failing != nil {
+		streak := *ag.Failing
+		result.Failing = &streak
+	}
+	result.Compliance = ag.Compliance.Copy()
+	result.Status = ag.Status
+	if result.Status == "" {
+		result.Status = model.UNKNOWN
+	}
 
-	expression, err := govaluate.NewEvaluableExpression(gt.Constraint)
+	expression, err := model.NewExpression(gt.Constraint)
 	if err != nil {
 		log.Warnf("Error parsing expression '%s'", gt.Constraint)
-		return nil, nil, err
+		return result, err
+	}
+	vars := expression.Vars()
+
+	var warning *model.Expression
+	if gt.Warning != "" {
+		warning, err = model.NewExpression(gt.Warning)
+		if err != nil {
+			log.Warnf("Error parsing warning expression '%s'", gt.Warning)
+			return result, err
+		}
+		vars = mergeVars(vars, warning.Vars())
+	}
+
+	values, err := monitor.WithContext(ma).GetValuesContext(ctx, gt, vars, now)
+	if err != nil {
+		return result, &monitor.RetrievalError{Guarantee: gt.AssessmentKey(), Err: err}
 	}
-	values := ma.GetValues(gt, expression.Vars(), now)
 	for _, value := range values {
 		aux, err := evaluateExpression(expression, value)
 		if err != nil {
 			log.Warn("Error evaluating expression " + gt.Constraint + ": " + err.Error())
-			return nil, nil, err
+			return result, err
 		}
+		if w := windows.Active(a, tupleTime(value)); w != nil {
+			if aux != nil && w.GetAction() == model.MaintenanceTag {
+				result.Violated = append(result.Violated, aux)
+			}
+			continue
+		}
+		result.Compliance.AddPoint(gt.Objective, tupleTime(value), aux != nil)
 		if aux != nil {
-			failed = append(failed, aux)
+			result.Failed = append(result.Failed, aux)
+			t := tupleTime(aux)
+			if result.Failing == nil {
+				result.Failing = &model.FailingStreak{Since: t}
+			}
+			result.Failing.Points++
+			result.Status = model.WARNING
+			if gt.Tolerance.IsViolated(*result.Failing, t) {
+				result.Failing.Violated = true
+				result.Violated = append(result.Violated, aux)
+				result.Status = model.VIOLATED
+			}
+			continue
+		}
+		if result.Failing != nil && result.Failing.Violated {
+			result.Recovered = append(result.Recovered, tupleTime(value))
+		}
+		result.Failing = nil
+		result.Status = model.FULFILLED
+		if warning == nil {
+			continue
+		}
+		aux, err = evaluateExpression(warning, value)
+		if err != nil {
+			log.Warn("Error evaluating warning expression " + gt.Warning + ": " + err.Error())
+			return result, err
+		}
+		if aux != nil {
+			result.Warned = append(result.Warned, aux)
+			result.Status = model.WARNING
 		}
 	}
 	if len(values) > 0 {
-		last = values[len(values)-1]
+		result.Last = values[len(values)-1]
+	}
+	result.NoData = noDataSince(gt, ag, values, now)
+	if result.NoData != nil {
+		result.Status = model.UNKNOWN
+	}
+	if result.NoData == nil && ag.NoDataSince != nil && gt.OnNoData == model.NoDataViolation {
+		recovery := now
+		if len(values) > 0 {
+			recovery = tupleTime(values[0])
+		}
+		result.Recovered = append([]time.Time{recovery}, result.Recovered...)
+	}
+	return result, nil
+}
+
+// noDataSince returns the time since a guarantee term has no fresh data, given its
+// assessment and the values retrieved in an evaluation at now. It returns nil if
+// the term has fresh data or its OnNoData policy is ignore.
+//
+// Without Staleness, the term has fresh data if there are values. With Staleness,
+// if the newest value, including the last values of the term, is not older than
+// Staleness seconds.
+func noDataSince(gt model.Guarantee, ag model.AssessmentGuarantee,
+	values amodel.GuaranteeData, now time.Time) *time.Time {
+
+	if gt.OnNoData == "" || gt.OnNoData == model.NoDataIgnore {
+		return nil
+	}
+	fresh := len(values) > 0
+	if gt.Staleness > 0 {
+		var newest time.Time
+		for _, v := range ag.LastValues {
+			if v.DateTime.After(newest) {
+				newest = v.DateTime
+			}
+		}
+		for _, tuple := range values {
+			if t := tupleTime(tuple); t.After(newest) {
+				newest = t
+			}
+		}
+		fresh = !newest.IsZero() && now.Sub(newest) <= time.Duration(gt.Staleness)*time.Second
 	}
-	return failed, last, nil
+	if fresh {
+		return nil
+	}
+	if ag.NoDataSince != nil {
+		since := *ag.NoDataSince
+		return &since
+	}
+	return &now
+}
+
+// mergeVars returns the union of two lists of variable names, keeping the order
+func mergeVars(vars []string, others []string) []string {
+	result := append([]string{}, vars...)
+	for _, other := range others {
+		found := false
+		for _, v := range result {
+			if v == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, other)
+		}
+	}
+	return result
 }
 
-// EvaluateGtViolations creates violations for the detected violated metrics in EvaluateGuarantee
-func EvaluateGtViolations(a *model.Agreement, gt model.Guarantee, violated amodel.GuaranteeData) []model.Violation {
+// EvaluateGtViolations creates violations for the detected violated metrics in EvaluateGuarantee.
+//
+// The violations raised during a maintenance window of the agreement (see
+// model.MaintenanceWindow) are discarded or tagged with the window.
+func EvaluateGtViolations(a *model.Agreement, gt model.Guarantee, violated amodel.GuaranteeData,
+	windows model.MaintenanceWindows) []model.Violation {
 	gtv := make([]model.Violation, 0, len(violated))
 	for _, tuple := range violated {
-		// build values map and find newer metric
-		var d *time.Time
+		// build values map
 		var values = make([]model.MetricValue, 0, len(tuple))
 		for _, m := range tuple {
 			values = append(values, m)
-			if d == nil || m.DateTime.After(*d) {
-				d = &m.DateTime
-			}
 		}
 		v := model.Violation{
 			AgreementId: a.Id,
 			Guarantee:   gt.Name,
-			Datetime:    *d,
+			Datetime:    tupleTime(tuple),
 			Constraint:  gt.Constraint,
 			Values:      values,
+			Member:      gt.Member(),
 		}
 		gtv = append(gtv, v)
 	}
-	return gtv
+	return applyMaintenance(a, gtv, windows)
+}
+
+// applyMaintenance discards or tags the violations of the agreement a raised during
+// an active maintenance window, according to the window action.
+func applyMaintenance(a *model.Agreement, violations []model.Violation,
+	windows model.MaintenanceWindows) []model.Violation {
+
+	if len(windows) == 0 {
+		return violations
+	}
+	result := violations[:0]
+	for _, v := range violations {
+		w := windows.Active(a, v.Datetime)
+		if w == nil {
+			result = append(result, v)
+			continue
+		}
+		if w.GetAction() == model.MaintenanceTag {
+			v.Maintenance = w.Id
+			result = append(result, v)
+			continue
+		}
+		log.Debugf("Skipping violation of guarantee %s of agreement %s at %v: in maintenance window %s",
+			v.Guarantee, a.Id, v.Datetime, w.Id)
+	}
+	return result
+}
+
+// noDataViolation creates the violation raised when a guarantee term stops having data
+func noDataViolation(a *model.Agreement, gt model.Guarantee, now time.Time) model.Violation {
+	return model.Violation{
+		AgreementId: a.Id,
+		Guarantee:   gt.Name,
+		Datetime:    now,
+		Constraint:  gt.Constraint,
+		Values:      []model.MetricValue{},
+		Member:      gt.Member(),
+		NoData:      true,
+	}
+}
+
+// tupleTime returns the time of the newer metric in a tuple
+func tupleTime(tuple amodel.ExpressionData) time.Time {
+	var d time.Time
+	for _, m := range tuple {
+		if d.IsZero() || m.DateTime.After(d) {
+			d = m.DateTime
+		}
+	}
+	return d
+}
+
+// EvaluateGtPenalties creates the penalties raised by a violation of a guarantee term,
+// one per PenaltyDef in the guarantee term. The violation must have an id.
+func EvaluateGtPenalties(gt model.Guarantee, v model.Violation) []model.Penalty {
+	result := make([]model.Penalty, 0, len(gt.Penalties))
+	for _, def := range gt.Penalties {
+		p := model.Penalty{
+			AgreementId: v.AgreementId,
+			Guarantee:   v.Guarantee,
+			ViolationId: v.Id,
+			Datetime:    v.Datetime,
+			Definition:  def,
+		}
+		result = append(result, p)
+	}
+	return result
 }
 
 // evaluateExpression evaluate a GT expression at a single point in time with a tuple of metric values
-// (one value per variable in GT expresssion)
+// (one value per variable in GT expresssion). The time of the newer value is the time
+// used by the hour() and weekday() functions.
 //
 // The result is: the values if the expression is false (i.e., the failing values) ,
-// or nil if expression was true
-func evaluateExpression(expression *govaluate.EvaluableExpression, values amodel.ExpressionData) (amodel.ExpressionData, error) {
+// or nil if expression was true. An error is returned if the expression does not
+// evaluate to a boolean.
+func evaluateExpression(expression *model.Expression, values amodel.ExpressionData) (amodel.ExpressionData, error) {
 
 	evalues := make(map[string]interface{})
 	for key, value := range values {
 		evalues[key] = value.Value
 	}
-	result, err := expression.Evaluate(evalues)
+	result, err := expression.EvaluateAt(evalues, tupleTime(values))
 	log.Debugf("Evaluating expression '%v'=%v with values %v", expression, result, values)
 
-	if err == nil && !result.(bool) {
+	if err != nil {
+		return nil, err
+	}
+	b, ok := result.(bool)
+	if !ok {
+		return nil, fmt.Errorf("Expression '%v' evaluated to non boolean value %v", expression, result)
+	}
+	if !b {
 		return values, nil
 	}
-	return nil, err
+	return nil, nil
 }
 
 // BuildRetrievalItems returns the RetrievalItems to be passed to an EarlyRetriever.
@@ -234,6 +996,7 @@ func BuildRetrievalItems(a *model.Agreement,
 	result := make([]monitor.RetrievalItem, 0, len(varnames))
 
 	defaultFrom := getDefaultFrom(a, gt)
+	member := gt.Member()
 	for _, name := range varnames {
 		v, _ := a.Details.GetVariable(name)
 		from := getFromForVariable(v, defaultFrom, to)
@@ -242,6 +1005,7 @@ func BuildRetrievalItems(a *model.Agreement,
 			Var:       v,
 			From:      from,
 			To:        to,
+			Member:    member,
 		}
 		result = append(result, item)
 	}
@@ -249,7 +1013,7 @@ func BuildRetrievalItems(a *model.Agreement,
 }
 
 func getDefaultFrom(a *model.Agreement, gt model.Guarantee) time.Time {
-	var defaultFrom = a.Assessment.GetGuarantee(gt.Name).LastExecution
+	var defaultFrom = a.Assessment.GetGuarantee(gt.AssessmentKey()).LastExecution
 	if defaultFrom.IsZero() {
 		defaultFrom = a.Assessment.LastExecution
 	}
@@ -262,13 +1026,14 @@ func getDefaultFrom(a *model.Agreement, gt model.Guarantee) time.Time {
 /*
 GetFromForVariable returns the interval start for the query to monitoring.
 
-If the variable is aggregated, it depends on the aggregation window.
+If the variable is aggregated, it depends on the aggregation window; a calendar
+window starts at the boundary of the calendar period that contains "to".
 If not, returns defaultFrom (which should be the last time the guarantee term
 was evaluated)
 */
 func getFromForVariable(v model.Variable, defaultFrom, to time.Time) time.Time {
-	if v.Aggregation != nil && v.Aggregation.Window != 0 {
-		return to.Add(-time.Duration(v.Aggregation.Window) * time.Second)
+	if from, ok := v.Aggregation.From(to); ok {
+		return from
 	}
 	return defaultFrom
 }
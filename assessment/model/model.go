@@ -33,15 +33,43 @@ type GuaranteeData []ExpressionData
 //
 // It contains the failed metrics and associated violations if any.
 type EvaluationGtResult struct {
-	Metrics    GuaranteeData     // violent metrics
-	Violations []model.Violation // violations occurred as of violated metrics
+	Metrics    GuaranteeData     `json:"metrics"`    // violent metrics
+	Violations []model.Violation `json:"violations"` // violations occurred as of violated metrics
+}
+
+// GuaranteeEvaluation is the outcome of evaluating a guarantee term
+// against the values retrieved from monitoring.
+type GuaranteeEvaluation struct {
+	Failed   GuaranteeData        // values that failed the constraint
+	Violated GuaranteeData        // failed values that exceeded the tolerance of the term
+	Warned   GuaranteeData        // values that fulfilled the constraint but failed the warning
+	Last     ExpressionData       // last values retrieved
+	Failing  *model.FailingStreak // failing streak after the evaluation; nil if none
+	// Recovered contains the times of the points that fulfilled the constraint
+	// right after violated points
+	Recovered []time.Time
+	// Compliance is the compliance of the term after accounting the evaluated points
+	Compliance *model.Compliance
+	// NoData is the time since the term has no fresh data, if its OnNoData policy
+	// is not ignore; nil if the term has fresh data
+	NoData *time.Time
+	// Status is the status of the term after the evaluation
+	Status model.Status
 }
 
 // Result is the result of the agreement assessment
+// swagger:model EvaluationResult
 type Result struct {
-	Violated      map[string]EvaluationGtResult // terms that were violated
-	LastValues    map[string]ExpressionData     // last value of variables in the term
-	LastExecution map[string]time.Time          // last execution of a guarantee
+	Violated      map[string]EvaluationGtResult    `json:"violated"`       // terms that were violated
+	Warned        map[string]GuaranteeData         `json:"warned"`         // terms whose warning threshold was breached
+	LastValues    map[string]ExpressionData        `json:"last_values"`    // last value of variables in the term
+	Failing       map[string]*model.FailingStreak  `json:"failing"`        // failing streak of the evaluated terms
+	Recovered     map[string][]time.Time           `json:"recovered"`      // times the violated terms recovered
+	Compliance    map[string]*model.Compliance     `json:"compliance"`     // compliance of the evaluated terms
+	Errors        map[string]model.EvaluationError `json:"errors"`         // terms that could not be evaluated
+	NoData        map[string]time.Time             `json:"no_data"`        // terms without fresh data, since when
+	Status        map[string]model.Status          `json:"status"`         // status of the evaluated terms
+	LastExecution map[string]time.Time             `json:"last_execution"` // last execution of a guarantee
 }
 
 // GetViolations return the violations contained in a Result
@@ -34,25 +34,40 @@ import (
 	amodel "SLALite/assessment/model"
 	"SLALite/assessment/monitor"
 	"SLALite/model"
+	"context"
+	"math"
 	"math/rand"
+	"sort"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 /*
 Adapter is the type of a customizable adapter.
 
 The Retrieve field is a function to query data to monitoring;
+the RetrieveContext field is its context-aware counterpart, and it is used
+instead of Retrieve if set;
 the Process field is a function to perform additional processing
 on data.
 
-Two Process functions are provided in the package:
-Identity (returns the input) and Aggregation (aggregates values according
-to the aggregation type)
+The Adapter implements monitor.ContextMonitoringAdapter.
+
+The values of a variable defined as a ratio are computed by the Adapter: the good
+and total metrics are retrieved and processed as separate variables
+(see model.Variable.RatioVariables()), and then divided.
+
+Three Process functions are provided in the package:
+Identity (returns the input), Aggregation (aggregates values according
+to the aggregation type) and AggregateHistograms (estimates quantiles from
+histogram values)
 */
 type Adapter struct {
-	Retrieve  Retrieve
-	Process   Process
-	agreement *model.Agreement
+	Retrieve        Retrieve
+	RetrieveContext RetrieveContext
+	Process         Process
+	agreement       *model.Agreement
 }
 
 // Retrieve is the type of the function that makes the actual request to monitoring.
@@ -61,6 +76,13 @@ type Adapter struct {
 type Retrieve func(agreement model.Agreement,
 	items []monitor.RetrievalItem) map[model.Variable][]model.MetricValue
 
+// RetrieveContext is the type of the function that makes the actual request to monitoring,
+// being able to be cancelled through ctx and to report errors.
+//
+// An error must be returned if the values could not be retrieved.
+type RetrieveContext func(ctx context.Context, agreement model.Agreement,
+	items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error)
+
 // Process is the type of the function that performs additional custom processing on
 // retrieved data.
 type Process func(v model.Variable, values []model.MetricValue) []model.MetricValue
@@ -73,6 +95,15 @@ func New(retrieve Retrieve, process Process) monitor.MonitoringAdapter {
 	}
 }
 
+// NewContext is a helper function to build an Adapter from a RetrieveContext and the
+// Process function.
+func NewContext(retrieve RetrieveContext, process Process) monitor.MonitoringAdapter {
+	return &Adapter{
+		RetrieveContext: retrieve,
+		Process:         process,
+	}
+}
+
 /*
 Initialize implements MonitoringAdapter.Initialize().
 
@@ -97,22 +128,53 @@ func (ga *Adapter) Initialize(a *model.Agreement) monitor.MonitoringAdapter {
 }
 
 // GetValues implements Monitoring.GetValues().
+//
+// Retrieval errors are logged and no values are returned.
 func (ga *Adapter) GetValues(gt model.Guarantee,
 	varnames []string,
 	now time.Time) amodel.GuaranteeData {
 
+	result, err := ga.GetValuesContext(context.Background(), gt, varnames, now)
+	if err != nil {
+		log.Errorf("Error getting values of guarantee %s: %s", gt.Name, err.Error())
+		return amodel.GuaranteeData{}
+	}
+	return result
+}
+
+// GetValuesContext implements ContextMonitoringAdapter.GetValuesContext().
+func (ga *Adapter) GetValuesContext(ctx context.Context,
+	gt model.Guarantee,
+	varnames []string,
+	now time.Time) (amodel.GuaranteeData, error) {
+
 	a := ga.agreement
 
 	items := assessment.BuildRetrievalItems(a, gt, varnames, now)
-	unprocessed := ga.Retrieve(*a, items)
+	retrieved := expandRatios(items)
+	var unprocessed map[model.Variable][]model.MetricValue
+	if ga.RetrieveContext != nil {
+		var err error
+		unprocessed, err = ga.RetrieveContext(ctx, *a, retrieved)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		unprocessed = ga.Retrieve(*a, retrieved)
+	}
 
 	/* process each of the series*/
 	valuesmap := map[model.Variable][]model.MetricValue{}
 	for v := range unprocessed {
 		valuesmap[v] = ga.Process(v, unprocessed[v])
 	}
-	result := Mount(valuesmap, lastvalues(a, gt), 0.1)
-	return result
+	interpolation := a.Details.Interpolation.Merge(model.DefaultInterpolation)
+	valuesmap = mergeRatios(valuesmap, items, interpolation)
+	result := MountInterpolated(valuesmap, lastvalues(a, gt), interpolation)
+	return result, nil
 }
 
 func lastvalues(a *model.Agreement, gt model.Guarantee) model.LastValues {
@@ -120,7 +182,7 @@ func lastvalues(a *model.Agreement, gt model.Guarantee) model.LastValues {
 	if a.Assessment.Guarantees == nil {
 		return empty
 	}
-	ag, ok := a.Assessment.Guarantees[gt.Name]
+	ag, ok := a.Assessment.Guarantees[gt.AssessmentKey()]
 	if !ok {
 		return empty
 	}
@@ -167,6 +229,40 @@ func (r DummyRetriever) Retrieve() Retrieve {
 	}
 }
 
+// MemoryRetriever retrieves the values from in-memory series of values, keyed by
+// metric name. The values of a metric for a scope member are keyed by
+// metric[member] (see model.MemberKey).
+//
+// All the values of a metric up to the end of the retrieval interval are returned,
+// regardless of the last time the guarantee term was evaluated, so that all of them
+// are evaluated. If the variable is aggregated over a window, only the values in the
+// window are returned.
+type MemoryRetriever map[string][]model.MetricValue
+
+// Retrieve returns the Retrieve function of the MemoryRetriever
+func (r MemoryRetriever) Retrieve() Retrieve {
+	return func(agreement model.Agreement,
+		items []monitor.RetrievalItem) map[model.Variable][]model.MetricValue {
+
+		result := map[model.Variable][]model.MetricValue{}
+		for _, item := range items {
+			v := item.Var
+			_, windowed := v.Aggregation.From(item.To)
+			series := r[model.MemberKey(v.Metric, item.Member)]
+			values := make([]model.MetricValue, 0, len(series))
+			for _, m := range series {
+				if m.DateTime.After(item.To) || windowed && m.DateTime.Before(item.From) {
+					continue
+				}
+				m.Key = v.Name
+				values = append(values, m)
+			}
+			result[v] = values
+		}
+		return result
+	}
+}
+
 // Identity returns the input
 func Identity(v model.Variable, values []model.MetricValue) []model.MetricValue {
 	return values
@@ -177,22 +273,75 @@ func Identity(v model.Variable, values []model.MetricValue) []model.MetricValue
 // This expects that all the values are in the appropriate window. For that,
 // the Retrieve function needs to return only the values in the window. If not,
 // this function will return an invalid result.
+//
+// The output is a single value with the time of the last input value, except
+// for rate and increase, that need at least two values and return no value otherwise.
+// If the variable is a counter, rate and increase take into account the resets
+// of the counter. Unknown aggregation types return the input.
+//
+// The values of histogram variables (see model.Variable.IsHistogram) are
+// aggregated with AggregateHistograms. Otherwise, the values that are not numbers
+// are ignored, and no value is returned if there are no numbers to aggregate.
 func Aggregate(v model.Variable, values []model.MetricValue) []model.MetricValue {
 	if len(values) == 0 || v.Aggregation == nil || v.Aggregation.Type == "" {
 		return values
 	}
-	if v.Aggregation.Type == model.AVERAGE {
-		avg := average(values)
-		return []model.MetricValue{
-			model.MetricValue{
-				Key:      v.Name,
-				Value:    avg,
-				DateTime: values[len(values)-1].DateTime,
-			},
+	if v.IsHistogram() {
+		return AggregateHistograms(v, values)
+	}
+	t := v.Aggregation.Type
+	if t == model.NONE || !t.IsValid() {
+		/* fallback */
+		return values
+	}
+	values = numbers(values)
+	if len(values) == 0 {
+		return values
+	}
+	var value float64
+	switch t {
+	case model.AVERAGE:
+		value = average(values)
+	case model.MIN:
+		value = minimum(values)
+	case model.MAX:
+		value = maximum(values)
+	case model.SUM:
+		value = sum(values)
+	case model.COUNT:
+		value = float64(len(values))
+	case model.MEDIAN:
+		value = percentile(values, 50)
+	case model.RATE, model.INCREASE:
+		if len(values) < 2 {
+			return []model.MetricValue{}
 		}
+		value = increase(values, v.IsCounter())
+		if t == model.RATE {
+			value = rate(values, value)
+		}
+	default:
+		p, _ := t.Percentile()
+		value = percentile(values, p)
+	}
+	return []model.MetricValue{
+		model.MetricValue{
+			Key:      v.Name,
+			Value:    value,
+			DateTime: values[len(values)-1].DateTime,
+		},
 	}
-	/* fallback */
-	return values
+}
+
+// numbers returns the values that are numbers
+func numbers(values []model.MetricValue) []model.MetricValue {
+	result := make([]model.MetricValue, 0, len(values))
+	for _, value := range values {
+		if _, ok := value.Value.(float64); ok {
+			result = append(result, value)
+		}
+	}
+	return result
 }
 
 func average(values []model.MetricValue) float64 {
@@ -204,3 +353,93 @@ func average(values []model.MetricValue) float64 {
 
 	return result
 }
+
+func sum(values []model.MetricValue) float64 {
+	result := 0.0
+	for _, value := range values {
+		result += value.Value.(float64)
+	}
+	return result
+}
+
+func minimum(values []model.MetricValue) float64 {
+	result := values[0].Value.(float64)
+	for _, value := range values[1:] {
+		result = math.Min(result, value.Value.(float64))
+	}
+	return result
+}
+
+func maximum(values []model.MetricValue) float64 {
+	result := values[0].Value.(float64)
+	for _, value := range values[1:] {
+		result = math.Max(result, value.Value.(float64))
+	}
+	return result
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the values,
+// interpolating linearly between the closest ranks.
+func percentile(values []model.MetricValue, p float64) float64 {
+	sorted := make([]float64, 0, len(values))
+	for _, value := range values {
+		sorted = append(sorted, value.Value.(float64))
+	}
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+/*
+increase returns the increase between the first and the last values by DateTime,
+which must be at least two.
+
+If counter is true, a decrease between two consecutive values is a reset of the
+counter, i.e., the counter went back to zero and increased up to the value after
+the reset.
+*/
+func increase(values []model.MetricValue, counter bool) float64 {
+	values = sortByTime(values)
+	first, last := values[0].Value.(float64), values[len(values)-1].Value.(float64)
+	if !counter {
+		return last - first
+	}
+	result := 0.0
+	prev := first
+	for _, value := range values[1:] {
+		current := value.Value.(float64)
+		if current < prev {
+			result += current
+		} else {
+			result += current - prev
+		}
+		prev = current
+	}
+	return result
+}
+
+// rate returns the per-second increase between the first and the last values by
+// DateTime, which must be at least two, given the increase between them.
+func rate(values []model.MetricValue, increase float64) float64 {
+	values = sortByTime(values)
+	first, last := values[0], values[len(values)-1]
+	seconds := last.DateTime.Sub(first.DateTime).Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return increase / seconds
+}
+
+// sortByTime returns a copy of the values sorted by DateTime. The order of the
+// values with the same DateTime is kept.
+func sortByTime(values []model.MetricValue) []model.MetricValue {
+	sorted := make([]model.MetricValue, len(values))
+	copy(sorted, values)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].DateTime.Before(sorted[j].DateTime)
+	})
+	return sorted
+}
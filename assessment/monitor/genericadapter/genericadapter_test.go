@@ -18,8 +18,12 @@ package genericadapter
 
 import (
 	"SLALite/assessment"
+	"SLALite/assessment/monitor"
 	"SLALite/model"
 	"SLALite/utils"
+	"context"
+	"errors"
+	"math"
 	"os"
 	"testing"
 	"time"
@@ -114,6 +118,106 @@ func testAverageWrongInput(t *testing.T, v model.Variable, values []model.Metric
 	}
 }
 
+func TestAggregations(t *testing.T) {
+	name := "agg"
+	t0 := time.Now()
+	values := newValues(name, t0, []m{
+		{0, 4}, {1, 2}, {2, 8}, {3, 6}, {4, 10},
+	})
+
+	expected := map[model.AggregationType]float64{
+		model.MIN:      2,
+		model.MAX:      10,
+		model.SUM:      30,
+		model.COUNT:    5,
+		model.MEDIAN:   6,
+		"p50":          6,
+		"p75":          8,
+		"p95":          9.6,
+		"p100":         10,
+		model.RATE:     1.5,
+		model.INCREASE: 6,
+	}
+	for aggtype, value := range expected {
+		v := model.Variable{
+			Name:        name,
+			Metric:      name,
+			Aggregation: &model.Aggregation{Type: aggtype},
+		}
+		output := Aggregate(v, values)
+		if len(output) != 1 {
+			t.Errorf("Unexpected %s values length. Expected: %d; Actual: %d", aggtype, 1, len(output))
+			continue
+		}
+		if actual := output[0].Value.(float64); math.Abs(actual-value) > 1e-9 {
+			t.Errorf("Unexpected %s. Expected: %f; Actual: %f", aggtype, value, actual)
+		}
+		if output[0].DateTime != values[len(values)-1].DateTime {
+			t.Errorf("Unexpected %s datetime: %v", aggtype, output[0].DateTime)
+		}
+	}
+
+	v := model.Variable{Name: name, Metric: name, Aggregation: &model.Aggregation{Type: model.RATE}}
+	if output := Aggregate(v, values[:1]); len(output) != 0 {
+		t.Errorf("Unexpected rate of a single value: %v", output)
+	}
+	v.Aggregation.Type = "unknown"
+	if output := Aggregate(v, values); len(output) != len(values) {
+		t.Errorf("Unexpected values length. Expected: %d; Actual: %d", len(values), len(output))
+	}
+}
+
+func TestCounterAggregations(t *testing.T) {
+	name := "requests"
+	t0 := time.Now()
+	values := newValues(name, t0, []m{
+		{0, 10}, {1, 15}, {2, 3}, {3, 8}, {4, 8},
+	})
+
+	counter := model.Variable{Name: name, Metric: name, Type: model.COUNTER}
+	gauge := model.Variable{Name: name, Metric: name, Type: model.GAUGE}
+	expected := []struct {
+		v       model.Variable
+		aggtype model.AggregationType
+		value   float64
+	}{
+		{counter, model.INCREASE, 13},
+		{counter, model.RATE, 3.25},
+		{gauge, model.INCREASE, -2},
+		{gauge, model.RATE, -0.5},
+	}
+	for _, e := range expected {
+		v := e.v
+		v.Aggregation = &model.Aggregation{Type: e.aggtype}
+		output := Aggregate(v, values)
+		if len(output) != 1 {
+			t.Errorf("Unexpected %s %s values length. Expected: %d; Actual: %d", v.Type, e.aggtype, 1, len(output))
+			continue
+		}
+		if actual := output[0].Value.(float64); math.Abs(actual-e.value) > 1e-9 {
+			t.Errorf("Unexpected %s %s. Expected: %f; Actual: %f", v.Type, e.aggtype, e.value, actual)
+		}
+		if output[0].Key != name || output[0].DateTime != values[len(values)-1].DateTime {
+			t.Errorf("Unexpected %s %s value: %v", v.Type, e.aggtype, output[0])
+		}
+	}
+
+	counter.Aggregation = &model.Aggregation{Type: model.INCREASE}
+	if output := Aggregate(counter, values[:1]); len(output) != 0 {
+		t.Errorf("Unexpected increase of a single value: %v", output)
+	}
+
+	/* the values are not sorted by time: an earlier value is not a reset */
+	unsorted := []model.MetricValue{values[1], values[0], values[3], values[2], values[4]}
+	for aggtype, value := range map[model.AggregationType]float64{model.INCREASE: 13, model.RATE: 3.25} {
+		counter.Aggregation = &model.Aggregation{Type: aggtype}
+		output := Aggregate(counter, unsorted)
+		if len(output) != 1 || math.Abs(output[0].Value.(float64)-value) > 1e-9 {
+			t.Errorf("Unexpected %s of unsorted values. Expected: %f; Actual: %v", aggtype, value, output)
+		}
+	}
+}
+
 func TestGenericAdapter(t *testing.T) {
 	retriever := DummyRetriever{3}
 	retrieve := retriever.Retrieve()
@@ -125,12 +229,123 @@ func TestGenericAdapter(t *testing.T) {
 	a, _ := utils.ReadAgreement("testdata/a.json")
 
 	ma := ga.Initialize(&a)
-	assessment.EvaluateAgreement(&a, ma, time.Now())
+	assessment.EvaluateAgreement(context.Background(), &a, ma, time.Now(), nil)
 	/*
 	 * Just tests that nothing breaks
 	 */
 }
 
+func TestGenericAdapterContext(t *testing.T) {
+	retrieve := DummyRetriever{3}.Retrieve()
+	a, _ := utils.ReadAgreement("testdata/a.json")
+	gt := a.Details.Guarantees[0]
+
+	ok := func(ctx context.Context, a model.Agreement,
+		items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error) {
+		return retrieve(a, items), ctx.Err()
+	}
+	ma := monitor.WithContext(NewContext(ok, Identity).Initialize(&a))
+	values, err := ma.GetValuesContext(context.Background(), gt, []string{"availability"}, time.Now())
+	if err != nil || len(values) == 0 {
+		t.Errorf("Unexpected result. Values: %v. Error: %v", values, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = ma.GetValuesContext(ctx, gt, []string{"availability"}, time.Now()); err == nil {
+		t.Errorf("Expected error on cancelled context")
+	}
+
+	failing := func(ctx context.Context, a model.Agreement,
+		items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error) {
+		return nil, errors.New("monitoring is down")
+	}
+	ma = monitor.WithContext(NewContext(failing, Identity).Initialize(&a))
+	if _, err = ma.GetValuesContext(context.Background(), gt, []string{"availability"}, time.Now()); err == nil {
+		t.Errorf("Expected retrieval error")
+	}
+	if values = ma.GetValues(gt, []string{"availability"}, time.Now()); len(values) != 0 {
+		t.Errorf("Unexpected values on retrieval error: %v", values)
+	}
+	result, err := assessment.EvaluateAgreement(context.Background(), &a, ma, time.Now(), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, ok := result.Errors[gt.Name]; !ok {
+		t.Errorf("Expected retrieval error in result. Actual: %v", result.Errors)
+	}
+	if _, err = assessment.EvaluateAgreement(ctx, &a, ma, time.Now(), nil); err == nil {
+		t.Errorf("Expected error on cancelled context")
+	}
+}
+
+func TestMemoryRetriever(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	values := []model.MetricValue{
+		{Value: 1.0, DateTime: T.T(0)},
+		{Value: 2.0, DateTime: T.T(1)},
+		{Value: 3.0, DateTime: T.T(2)},
+		{Value: 4.0, DateTime: T.T(3)},
+	}
+	retrieve := MemoryRetriever{"m": values}.Retrieve()
+
+	plain := model.Variable{Name: "plain", Metric: "m"}
+	windowed := model.Variable{Name: "windowed", Metric: "m", Aggregation: &model.Aggregation{Type: model.SUM, Window: 1}}
+	missing := model.Variable{Name: "missing", Metric: "doesnotexist"}
+	items := []monitor.RetrievalItem{
+		{Var: plain, From: T.T(2), To: T.T(2)},
+		{Var: windowed, From: T.T(1), To: T.T(2)},
+		{Var: missing, From: T.T(0), To: T.T(2)},
+	}
+	result := retrieve(model.Agreement{}, items)
+
+	if actual := result[plain]; len(actual) != 3 || actual[0].Key != "plain" {
+		t.Errorf("Unexpected values of plain variable: %v", actual)
+	}
+	if actual := result[windowed]; len(actual) != 2 || actual[0].Value != 2.0 || actual[0].Key != "windowed" {
+		t.Errorf("Unexpected values of windowed variable: %v", actual)
+	}
+	if actual, ok := result[missing]; !ok || len(actual) != 0 {
+		t.Errorf("Unexpected values of missing variable: %v", actual)
+	}
+	if values[0].Key != "" {
+		t.Errorf("Retrieved values must be copies")
+	}
+}
+
+func TestMemoryRetrieverWithScope(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	retriever := MemoryRetriever{
+		"m[host-a]": {{Value: 1.0, DateTime: T.T(0)}},
+		"m[host-b]": {{Value: -1.0, DateTime: T.T(0)}},
+	}
+	a := model.Agreement{
+		Id: "a01",
+		Details: model.Details{
+			Guarantees: []model.Guarantee{
+				{Name: "gt", Constraint: "m >= 0", Scope: model.Scope{"host-a", "host-b"}},
+			},
+		},
+	}
+	ma := New(retriever.Retrieve(), Identity)
+	result, err := assessment.EvaluateAgreement(context.Background(), &a, ma, T.T(1), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v := result.LastValues["gt[host-a]"]["m"].Value; v != 1.0 {
+		t.Errorf("Unexpected last value of host-a: %v", v)
+	}
+	violations := result.Violated["gt[host-b]"].Violations
+	if len(violations) != 1 || violations[0].Member != "host-b" || violations[0].Values[0].Value != -1.0 {
+		t.Errorf("Unexpected violations of host-b: %v", violations)
+	}
+	if _, ok := result.Violated["gt[host-a]"]; ok {
+		t.Errorf("Unexpected violations of host-a: %v", result.Violated["gt[host-a]"])
+	}
+}
+
 func newVar(name string) model.Variable {
 	return model.Variable{
 		Name:   name,
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genericadapter
+
+import (
+	"SLALite/model"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+AggregateHistograms is a Process function for variables whose values are
+histograms (see model.Histogram), i.e., variables of type model.HISTOGRAM or
+model.COUNTERHISTOGRAM.
+
+The histograms are merged into one, and the output depends on the aggregation
+type: median and percentiles (pNN) estimate the quantile from the merged buckets,
+interpolating linearly inside the bucket of the quantile; count returns the number
+of observations. The output is a single value with the time of the last input
+value, or no value if there are no observations.
+
+If the variable is a model.COUNTERHISTOGRAM, each bucket counts the observations since the
+counter started, and the merged histogram contains the increase of the buckets
+(so at least two histograms are needed). If not, each histogram contains its own
+observations, and the merged histogram contains the sum of the buckets.
+
+As in Aggregate, the Retrieve function needs to return only the values in the
+aggregation window. The values that are not histograms, or not valid histograms
+(see model.Histogram.Check), are ignored. Other aggregation types return the input.
+*/
+func AggregateHistograms(v model.Variable, values []model.MetricValue) []model.MetricValue {
+	if len(values) == 0 || v.Aggregation == nil {
+		return values
+	}
+	t := v.Aggregation.Type
+	p, ok := t.Percentile()
+	if t == model.MEDIAN {
+		p, ok = 50, true
+	}
+	if !ok && t != model.COUNT {
+		return values
+	}
+
+	h := mergeHistograms(values, v.IsCounter())
+	if h == nil || h.Total() <= 0 {
+		return []model.MetricValue{}
+	}
+	value := h.Total()
+	if t != model.COUNT {
+		value = quantile(h, p/100)
+	}
+	return []model.MetricValue{
+		model.MetricValue{
+			Key:      v.Name,
+			Value:    value,
+			DateTime: values[len(values)-1].DateTime,
+		},
+	}
+}
+
+/*
+mergeHistograms merges the histograms in values into a histogram whose buckets
+are the upper bounds of all of them. The count of a bucket in each histogram is
+the count of its closest bucket not above the bound (see model.Histogram.CountAt),
+so the merge is exact if all the histograms have the same buckets.
+
+If counter is true, the counts of the merged buckets are the increase of the
+counts (see increase) in the order of the DateTime of the values; nil is returned
+if there are less than two histograms.
+*/
+func mergeHistograms(values []model.MetricValue, counter bool) *model.Histogram {
+	histograms := make([]*model.Histogram, 0, len(values))
+	bounds := make([]float64, 0)
+	seen := map[float64]bool{}
+	for _, value := range sortByTime(values) {
+		h, ok := value.AsHistogram()
+		if !ok {
+			continue
+		}
+		if err := h.Check(); err != nil {
+			log.Warnf("Ignoring histogram %s at %v: %s", value.Key, value.DateTime, err.Error())
+			continue
+		}
+		histograms = append(histograms, h)
+		for _, b := range h.Buckets {
+			if !seen[b.UpperBound] {
+				seen[b.UpperBound] = true
+				bounds = append(bounds, b.UpperBound)
+			}
+		}
+	}
+	if len(histograms) == 0 || counter && len(histograms) < 2 {
+		return nil
+	}
+	sort.Float64s(bounds)
+
+	result := &model.Histogram{Buckets: make([]model.Bucket, 0, len(bounds))}
+	for _, bound := range bounds {
+		counts := make([]model.MetricValue, 0, len(histograms))
+		for _, h := range histograms {
+			counts = append(counts, model.MetricValue{Value: h.CountAt(bound)})
+		}
+		var count float64
+		if counter {
+			count = increase(counts, true)
+		} else {
+			count = sum(counts)
+		}
+		result.Buckets = append(result.Buckets, model.Bucket{UpperBound: bound, Count: count})
+	}
+	return result
+}
+
+/*
+quantile estimates the q-quantile (0 <= q <= 1) of the observations in h, which
+must have at least one observation.
+
+As in the histogram_quantile function of Prometheus, the observations in a
+bucket are supposed to be uniformly distributed between the upper bounds of the
+previous bucket and the bucket (or zero, for the first bucket).
+*/
+func quantile(h *model.Histogram, q float64) float64 {
+	rank := q * h.Total()
+	lowerBound, lowerCount := 0.0, 0.0
+	for i, b := range h.Buckets {
+		if b.Count >= rank {
+			if i == 0 && b.UpperBound <= 0 || b.Count == lowerCount {
+				return b.UpperBound
+			}
+			return lowerBound + (b.UpperBound-lowerBound)*(rank-lowerCount)/(b.Count-lowerCount)
+		}
+		lowerBound, lowerCount = b.UpperBound, b.Count
+	}
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genericadapter
+
+import (
+	"SLALite/assessment"
+	"SLALite/model"
+	"SLALite/utils"
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuantile(t *testing.T) {
+	h := histogram(b{100, 50}, b{200, 90}, b{300, 99}, b{500, 100})
+	expected := map[float64]float64{
+		0:    0,
+		0.25: 50,
+		0.5:  100,
+		0.95: 200 + 100*5/9.0,
+		0.99: 300,
+		1:    500,
+	}
+	for q, value := range expected {
+		if actual := quantile(h, q); math.Abs(actual-value) > 1e-9 {
+			t.Errorf("Unexpected %v-quantile. Expected: %v. Actual: %v", q, value, actual)
+		}
+	}
+}
+
+func TestMergeHistograms(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	values := []model.MetricValue{
+		{Value: histogram(b{100, 10}, b{200, 20}), DateTime: T.T(0)},
+		{Value: 1.0, DateTime: T.T(1)},
+		{Value: histogram(b{100, 60}, b{200, 110}), DateTime: T.T(2)},
+		{Value: histogram(b{150, 5}, b{200, 10}), DateTime: T.T(3)},
+	}
+
+	/* the counter is reset in the last histogram */
+	expected := map[bool]*model.Histogram{
+		false: histogram(b{100, 70}, b{150, 75}, b{200, 140}),
+		true:  histogram(b{100, 50}, b{150, 55}, b{200, 100}),
+	}
+	for counter, e := range expected {
+		h := mergeHistograms(values, counter)
+		if h == nil || !reflect.DeepEqual(*h, *e) {
+			t.Errorf("Unexpected merged histogram (counter=%v). Expected: %v. Actual: %v", counter, e, h)
+		}
+	}
+	/* the histograms are not sorted by time */
+	unsorted := []model.MetricValue{values[3], values[0], values[2], values[1]}
+	if h := mergeHistograms(unsorted, true); h == nil || !reflect.DeepEqual(*h, *expected[true]) {
+		t.Errorf("Unexpected merged histogram of unsorted counters. Expected: %v. Actual: %v", expected[true], h)
+	}
+	if h := mergeHistograms(values[:2], true); h != nil {
+		t.Errorf("Unexpected merged histogram of a single counter: %v", h)
+	}
+	if h := mergeHistograms(values[1:2], false); h != nil {
+		t.Errorf("Unexpected merged histogram without histograms: %v", h)
+	}
+}
+
+func TestAggregateHistograms(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	values := []model.MetricValue{
+		{Key: "latency", Value: histogram(b{100, 30}, b{200, 45}, b{300, 50}), DateTime: T.T(0)},
+		{Key: "latency", Value: histogram(b{100, 20}, b{200, 45}, b{300, 50}), DateTime: T.T(1)},
+	}
+	expected := map[model.AggregationType]float64{
+		model.MEDIAN: 100,
+		"p95":        250,
+		model.COUNT:  100,
+	}
+	for aggtype, value := range expected {
+		v := model.Variable{Name: "latency_" + string(aggtype), Metric: "latency", Type: model.HISTOGRAM,
+			Aggregation: &model.Aggregation{Type: aggtype}}
+		output := Aggregate(v, values)
+		if len(output) != 1 {
+			t.Errorf("Unexpected %s values length. Expected: %d; Actual: %d", aggtype, 1, len(output))
+			continue
+		}
+		if actual := output[0].Value.(float64); math.Abs(actual-value) > 1e-9 {
+			t.Errorf("Unexpected %s. Expected: %f; Actual: %f", aggtype, value, actual)
+		}
+		if output[0].Key != v.Name || output[0].DateTime != T.T(1) {
+			t.Errorf("Unexpected %s value: %v", aggtype, output[0])
+		}
+	}
+
+	v := model.Variable{Name: "latency", Metric: "latency", Type: model.HISTOGRAM, Aggregation: &model.Aggregation{Type: model.MAX}}
+	if output := AggregateHistograms(v, values); len(output) != len(values) {
+		t.Errorf("Unexpected values length. Expected: %d; Actual: %d", len(values), len(output))
+	}
+	empty := []model.MetricValue{{Value: histogram(b{100, 0})}}
+	v.Aggregation.Type = "p99"
+	if output := AggregateHistograms(v, empty); len(output) != 0 {
+		t.Errorf("Unexpected quantile of empty histogram: %v", output)
+	}
+}
+
+func TestAggregateInvalidHistograms(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	values := []model.MetricValue{
+		{Key: "latency", Value: histogram(b{100, 30}, b{200, 45}, b{300, 50}), DateTime: T.T(0)},
+		{Key: "latency", Value: histogram(b{300, 50}, b{100, 20}, b{200, 45}), DateTime: T.T(1)},
+		{Key: "latency", Value: histogram(b{100, 40}, b{200, 10}, b{300, 50}), DateTime: T.T(2)},
+	}
+
+	/* the histograms with out of order buckets or decreasing counts are ignored */
+	v := model.Variable{Name: "latency", Metric: "latency", Type: model.HISTOGRAM,
+		Aggregation: &model.Aggregation{Type: model.MEDIAN}}
+	output := AggregateHistograms(v, values)
+	if len(output) != 1 || output[0].Value != 100*25/30.0 || output[0].DateTime != T.T(2) {
+		t.Errorf("Unexpected median of the valid histogram: %v", output)
+	}
+	if output := AggregateHistograms(v, values[1:]); len(output) != 0 {
+		t.Errorf("Unexpected median of invalid histograms: %v", output)
+	}
+
+	v.Type = model.COUNTERHISTOGRAM
+	v.Aggregation.Type = model.COUNT
+	if output := AggregateHistograms(v, values); len(output) != 0 {
+		t.Errorf("Unexpected count of a single valid counter: %v", output)
+	}
+}
+
+func TestAggregateMixedValues(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	values := []model.MetricValue{
+		{Key: "latency", Value: 10.0, DateTime: T.T(0)},
+		{Key: "latency", Value: histogram(b{100, 30}, b{200, 45}), DateTime: T.T(1)},
+		{Key: "latency", Value: 30.0, DateTime: T.T(2)},
+		{Key: "latency", Value: "NaN", DateTime: T.T(3)},
+	}
+	v := model.Variable{Name: "latency", Metric: "latency", Aggregation: &model.Aggregation{Type: model.AVERAGE}}
+	output := Aggregate(v, values)
+	if len(output) != 1 || output[0].Value != 20.0 || output[0].DateTime != T.T(2) {
+		t.Errorf("Unexpected average of the numbers: %v", output)
+	}
+
+	v.Type = model.HISTOGRAM
+	v.Aggregation.Type = model.COUNT
+	output = Aggregate(v, values)
+	if len(output) != 1 || output[0].Value != 45.0 {
+		t.Errorf("Unexpected count of the histograms: %v", output)
+	}
+
+	v.Type = model.GAUGE
+	v.Aggregation.Type = model.MAX
+	if output := Aggregate(v, values[1:2]); len(output) != 0 {
+		t.Errorf("Unexpected max without numbers: %v", output)
+	}
+}
+
+func TestGenericAdapterWithHistograms(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	a := model.Agreement{
+		Id: "a01",
+		Details: model.Details{
+			Creation: T.T(-3600),
+			Variables: []model.Variable{
+				{Name: "latency_p99", Metric: "latency", Type: model.COUNTERHISTOGRAM,
+					Aggregation: &model.Aggregation{Type: "p99", Window: 600}},
+			},
+			Guarantees: []model.Guarantee{{Name: "gt", Constraint: "latency_p99 < 300"}},
+		},
+	}
+	retriever := MemoryRetriever{
+		"latency": {
+			{Value: histogram(b{100, 0}, b{300, 0}, b{1000, 0}), DateTime: T.T(-1200)},
+			{Value: histogram(b{100, 100}, b{300, 150}, b{1000, 150}), DateTime: T.T(-600)},
+			{Value: histogram(b{100, 180}, b{300, 240}, b{1000, 250}), DateTime: T.T(0)},
+		},
+	}
+
+	/* in the window: 80 observations <= 100, 10 <= 300, 10 <= 1000 */
+	ma := New(retriever.Retrieve(), Aggregate).Initialize(&a)
+	values := ma.GetValues(a.Details.Guarantees[0], []string{"latency_p99"}, T.T(0))
+	if len(values) != 1 {
+		t.Fatalf("Unexpected values. Expected: 1. Actual: %v", values)
+	}
+	if actual := values[0]["latency_p99"].Value.(float64); math.Abs(actual-930) > 1e-9 {
+		t.Errorf("Unexpected latency_p99. Expected: 930. Actual: %v", actual)
+	}
+
+	result, err := assessment.EvaluateAgreement(context.Background(), &a, ma, T.T(0), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(result.Violated) != 1 {
+		t.Errorf("Unexpected violated terms. Expected: 1. Actual: %v", result.Violated)
+	}
+}
+
+type b struct {
+	le    float64
+	count float64
+}
+
+func histogram(buckets ...b) *model.Histogram {
+	result := &model.Histogram{Buckets: make([]model.Bucket, 0, len(buckets))}
+	for _, bucket := range buckets {
+		result.Buckets = append(result.Buckets, model.Bucket{UpperBound: bucket.le, Count: bucket.count})
+	}
+	return result
+}
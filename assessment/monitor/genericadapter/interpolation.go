@@ -35,8 +35,9 @@ type mountCtx struct {
 	lens map[model.Variable]int
 	// maxlen contains the maximum length
 	maxlen int
-	// maxdelta is maximum time allowed for metrics to be considered in the same pointset
-	maxdelta float64
+	// interpolations contains the interpolation of each variable; the delta is the maximum
+	// time allowed for metrics to be considered in the same pointset
+	interpolations map[model.Variable]model.Interpolation
 	// sumlens is the sum of the series lengths in values
 	sumlens int
 }
@@ -46,7 +47,8 @@ var _INF = time.Unix(1<<61, 0)
 
 /*
 Mount builds the GuaranteeData structure, directly used for agreement assessment,
-considering constant interpolation.
+considering constant interpolation and a maxdelta in seconds, unless the variables
+set their own interpolation (see MountInterpolated).
 
 Constant interpolation means that for a variable whose value is not known at a time t,
 it is considered that it has the value of last known value.
@@ -83,7 +85,28 @@ func Mount(valuesmap map[model.Variable][]model.MetricValue,
 	lastvalues map[string]model.MetricValue,
 	maxdelta float64) amodel.GuaranteeData {
 
-	ctx := initCtx(valuesmap, lastvalues, maxdelta)
+	return MountInterpolated(valuesmap, lastvalues, model.NewInterpolation(model.CONSTANT, maxdelta))
+}
+
+/*
+MountInterpolated builds the GuaranteeData structure as Mount does, but the
+interpolation is set per variable: the Interpolation of the variable, with the
+empty fields taken from defaults.
+
+For a variable without a value in the delta of a point set, the value depends on
+the interpolation type. With constant interpolation, it is the last known value.
+With linear interpolation, it is the value at the time of the point set in the line
+between the last known value and the next value of the variable; if there is no next
+value, or the values are not numeric, it is the last known value. With strict
+interpolation, the point set is discarded.
+
+If there is no last known value, the point set is discarded.
+*/
+func MountInterpolated(valuesmap map[model.Variable][]model.MetricValue,
+	lastvalues map[string]model.MetricValue,
+	defaults model.Interpolation) amodel.GuaranteeData {
+
+	ctx := initCtx(valuesmap, lastvalues, defaults)
 
 	result := make(amodel.GuaranteeData, 0, ctx.maxlen)
 
@@ -109,19 +132,21 @@ func Mount(valuesmap map[model.Variable][]model.MetricValue,
 
 func initCtx(valuesmap map[model.Variable][]model.MetricValue,
 	lastvalues map[string]model.MetricValue,
-	maxdelta float64) mountCtx {
+	defaults model.Interpolation) mountCtx {
 
 	if lastvalues == nil {
 		lastvalues = model.LastValues{}
 	}
 	index := make(map[model.Variable]int)
 	lens := make(map[model.Variable]int)
+	interpolations := make(map[model.Variable]model.Interpolation)
 	max := 0
 	sum := 0
 
 	for v := range valuesmap {
 		// fill index
 		index[v] = 0
+		interpolations[v] = v.Interpolation.Merge(defaults)
 
 		// lens and calculate maximum length
 		l := len(valuesmap[v])
@@ -132,13 +157,13 @@ func initCtx(valuesmap map[model.Variable][]model.MetricValue,
 		sum += l
 	}
 	ctx := mountCtx{
-		values:   valuesmap,
-		last:     lastvalues,
-		index:    index,
-		lens:     lens,
-		maxlen:   max,
-		maxdelta: maxdelta,
-		sumlens:  sum,
+		values:         valuesmap,
+		last:           lastvalues,
+		index:          index,
+		lens:           lens,
+		maxlen:         max,
+		interpolations: interpolations,
+		sumlens:        sum,
 	}
 	return ctx
 }
@@ -171,20 +196,48 @@ func (ctx *mountCtx) buildNextPointSet(nextp model.MetricValue) (amodel.Expressi
 	var discard = false
 
 	for v := range ctx.values {
+		interpolation := ctx.interpolations[v]
 		value := ctx.getCurrentValue(v)
-		if deltaTimes(nextp, value) <= ctx.maxdelta {
+		if deltaTimes(nextp, value) <= interpolation.GetDelta() {
 			data[v.Name] = value
 			ctx.index[v]++
 			ctx.last[v.Name] = value
-		} else if _, ok := ctx.last[v.Name]; !ok {
+			continue
+		}
+		last, ok := ctx.last[v.Name]
+		switch {
+		case !ok || interpolation.Type == model.STRICT:
 			discard = true
-		} else {
-			data[v.Name] = ctx.last[v.Name]
+		case interpolation.Type == model.LINEAR:
+			data[v.Name] = interpolate(last, value, nextp.DateTime)
+		default:
+			data[v.Name] = last
 		}
 	}
 	return data, !discard
 }
 
+/*
+interpolate returns the value at time t in the line between the values prev and next.
+
+It returns prev if next is in the infinite future (i.e., there is no next value) or
+any of the values is not numeric.
+*/
+func interpolate(prev, next model.MetricValue, t time.Time) model.MetricValue {
+	p, okp := prev.Value.(float64)
+	n, okn := next.Value.(float64)
+	span := next.DateTime.Sub(prev.DateTime).Seconds()
+	if !okp || !okn || next.DateTime == _INF || span <= 0 {
+		return prev
+	}
+	elapsed := t.Sub(prev.DateTime).Seconds()
+	return model.MetricValue{
+		Key:      prev.Key,
+		Value:    p + (n-p)*elapsed/span,
+		DateTime: t,
+	}
+}
+
 /*
 getCurrentValue returns the next value of a variable according to the
 index.
@@ -37,6 +37,7 @@ import (
 	amodel "SLALite/assessment/model"
 	"SLALite/model"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 )
@@ -60,7 +61,7 @@ func TestFindNextPoint(t *testing.T) {
 
 	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, v2: v2V, v3: v3V}
 	lastvalues := map[string]model.MetricValue{}
-	ctx := initCtx(valuesmap, lastvalues, 0.2)
+	ctx := initCtx(valuesmap, lastvalues, model.NewInterpolation(model.CONSTANT, 0.2))
 
 	p := ctx.findNextPoint()
 	if p != v1V[0] {
@@ -88,7 +89,7 @@ func TestBuildNextPointSet(t *testing.T) {
 
 	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, v2: v2V, v3: v3V}
 	lastvalues := map[string]model.MetricValue{}
-	ctx := initCtx(valuesmap, lastvalues, 0.2)
+	ctx := initCtx(valuesmap, lastvalues, model.NewInterpolation(model.CONSTANT, 0.2))
 
 	p := ctx.findNextPoint()
 	data, ok := ctx.buildNextPointSet(p)
@@ -156,6 +157,69 @@ func TestMount(t *testing.T) {
 
 }
 
+func TestMountStrict(t *testing.T) {
+	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, v2: v2V, v3: v3V}
+	strict := model.NewInterpolation(model.STRICT, 0.2)
+
+	pointsets := MountInterpolated(valuesmap, map[string]model.MetricValue{}, strict)
+	if len(pointsets) != 1 {
+		t.Fatalf("Unexpected number of pointsets. Expected: %d; Actual: %d", 1, len(pointsets))
+	}
+	assertPointSet(t, pointsets[0], v1V[1], v2V[1], v3V[1])
+}
+
+func TestMountLinear(t *testing.T) {
+	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, v2: v2V, v3: v3V}
+	linear := model.NewInterpolation(model.LINEAR, 0.2)
+
+	pointsets := MountInterpolated(valuesmap, map[string]model.MetricValue{}, linear)
+	if len(pointsets) != 5 {
+		t.Fatalf("Unexpected number of pointsets. Expected: %d; Actual: %d", 5, len(pointsets))
+	}
+	/* t = 2 */
+	checkInterpolated(t, pointsets[0][v1.Name], v3V[0].DateTime, 1.9/3.9)
+	checkInterpolated(t, pointsets[0][v2.Name], v3V[0].DateTime, 2+1/2.9)
+	if pointsets[0][v3.Name] != v3V[0] {
+		t.Errorf("Unexpected aligned value. Expected: %v; Actual: %v", v3V[0], pointsets[0][v3.Name])
+	}
+	/* t = 9; v1 has no next value */
+	if pointsets[4][v1.Name] != v1V[2] {
+		t.Errorf("Unexpected last value. Expected: %v; Actual: %v", v1V[2], pointsets[4][v1.Name])
+	}
+}
+
+func TestMountWithVariableInterpolation(t *testing.T) {
+	strict := v2
+	strict.Interpolation = &model.Interpolation{Type: model.STRICT}
+	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, strict: v2V, v3: v3V}
+
+	pointsets := MountInterpolated(valuesmap, map[string]model.MetricValue{}, model.NewInterpolation(model.CONSTANT, 0.2))
+	if len(pointsets) != 2 {
+		t.Fatalf("Unexpected number of pointsets. Expected: %d; Actual: %d", 2, len(pointsets))
+	}
+	assertPointSet(t, pointsets[0], v1V[1], v2V[1], v3V[1])
+	assertPointSet(t, pointsets[1], v1V[2], v2V[2], v3V[1])
+}
+
+func TestMountWithZeroDelta(t *testing.T) {
+	exact := v3
+	zero := 0.0
+	exact.Interpolation = &model.Interpolation{Delta: &zero}
+	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, v2: v2V, exact: v3V}
+
+	/* v3 values are not aligned with the values of v1 and v2, that are 0.1s-0.2s apart */
+	pointsets := MountInterpolated(valuesmap, map[string]model.MetricValue{}, model.NewInterpolation(model.STRICT, 0.2))
+	if len(pointsets) != 0 {
+		t.Errorf("Unexpected pointsets with zero delta: %v", pointsets)
+	}
+}
+
+func checkInterpolated(t *testing.T, actual model.MetricValue, expectedTime time.Time, expected float64) {
+	if !actual.DateTime.Equal(expectedTime) || math.Abs(actual.Value.(float64)-expected) > 1e-6 {
+		t.Errorf("Unexpected interpolated value. Expected: %v at %v; Actual: %v", expected, expectedTime, actual)
+	}
+}
+
 func TestEmptySeries(t *testing.T) {
 	empty := newValues(v3.Metric, t0, []m{})
 	valuesmap := map[model.Variable][]model.MetricValue{v1: v1V, v2: v2V, v3: empty}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genericadapter
+
+import (
+	"SLALite/assessment/monitor"
+	"SLALite/model"
+)
+
+/*
+expandRatios replaces the retrieval item of each ratio variable with the items of
+its good and total metrics (see model.Variable.RatioVariables()), so that the
+retrieval functions only deal with single metrics.
+
+The interval of each metric starts at the window of its own aggregation, if any,
+or at the start of the item of the ratio variable.
+*/
+func expandRatios(items []monitor.RetrievalItem) []monitor.RetrievalItem {
+	result := make([]monitor.RetrievalItem, 0, len(items))
+	for _, item := range items {
+		if !item.Var.IsRatio() {
+			result = append(result, item)
+			continue
+		}
+		good, total := item.Var.RatioVariables()
+		for _, v := range []model.Variable{good, total} {
+			from, ok := v.Aggregation.From(item.To)
+			if !ok {
+				from = item.From
+			}
+			result = append(result, monitor.RetrievalItem{
+				Guarantee: item.Guarantee,
+				Var:       v,
+				From:      from,
+				To:        item.To,
+				Member:    item.Member,
+			})
+		}
+	}
+	return result
+}
+
+// mergeRatios replaces in valuesmap the processed values of the good and total
+// metrics of the ratio variables in items with the values of the ratio.
+func mergeRatios(valuesmap map[model.Variable][]model.MetricValue,
+	items []monitor.RetrievalItem,
+	defaults model.Interpolation) map[model.Variable][]model.MetricValue {
+
+	for _, item := range items {
+		v := item.Var
+		if !v.IsRatio() {
+			continue
+		}
+		good, total := v.RatioVariables()
+		valuesmap[v] = ratio(v, valuesmap[good], valuesmap[total], defaults)
+		delete(valuesmap, good)
+		delete(valuesmap, total)
+	}
+	return valuesmap
+}
+
+/*
+ratio returns the values of the ratio variable v from the values of its good
+and total metrics.
+
+The good and total values are aligned in time as the variables of a guarantee
+term are (see MountInterpolated). The time of each ratio value is the latest time
+of the pair of values. Pairs with a non-numeric value or a total of zero (i.e.,
+there are no events) do not produce a value.
+*/
+func ratio(v model.Variable,
+	goodValues, totalValues []model.MetricValue,
+	defaults model.Interpolation) []model.MetricValue {
+
+	good, total := v.RatioVariables()
+	data := MountInterpolated(
+		map[model.Variable][]model.MetricValue{good: goodValues, total: totalValues},
+		nil,
+		defaults)
+
+	scale := v.Ratio.GetScale()
+	result := make([]model.MetricValue, 0, len(data))
+	for _, point := range data {
+		g, okg := point[good.Name].Value.(float64)
+		t, okt := point[total.Name].Value.(float64)
+		if !okg || !okt || t == 0 {
+			continue
+		}
+		when := point[good.Name].DateTime
+		if point[total.Name].DateTime.After(when) {
+			when = point[total.Name].DateTime
+		}
+		result = append(result, model.MetricValue{
+			Key:      v.Name,
+			Value:    g / t * scale,
+			DateTime: when,
+		})
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genericadapter
+
+import (
+	"SLALite/assessment"
+	"SLALite/assessment/monitor"
+	"SLALite/model"
+	"SLALite/utils"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRatio(t *testing.T) {
+	t0 := time.Now()
+	v := model.Variable{
+		Name: "availability",
+		Ratio: &model.Ratio{
+			Good:  model.RatioMetric{Metric: "ok"},
+			Total: model.RatioMetric{Metric: "requests"},
+			Scale: 100,
+		},
+	}
+	good := newValues("availability.good", t0, []m{{0, 9}, {1, 0}, {2, 5}})
+	total := newValues("availability.total", t0, []m{{0, 10}, {1, 0}, {2.05, 5}})
+
+	result := ratio(v, good, total, model.DefaultInterpolation)
+	if len(result) != 2 {
+		t.Fatalf("Unexpected ratio values. Expected: 2. Actual: %v", result)
+	}
+	if result[0].Value != 90.0 || result[0].Key != v.Name || result[0].DateTime != t0 {
+		t.Errorf("Unexpected ratio value: %v", result[0])
+	}
+	if result[1].Value != 100.0 || result[1].DateTime != total[2].DateTime {
+		t.Errorf("Unexpected ratio value: %v", result[1])
+	}
+}
+
+func TestGenericAdapterWithRatio(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	hour := model.Aggregation{Type: model.SUM, Window: 3600}
+	v := model.Variable{
+		Name: "availability",
+		Ratio: &model.Ratio{
+			Good:  model.RatioMetric{Metric: "ok", Aggregation: &hour},
+			Total: model.RatioMetric{Metric: "requests", Aggregation: &hour},
+			Scale: 100,
+		},
+	}
+	a := model.Agreement{
+		Id: "a01",
+		Details: model.Details{
+			Creation:   T.T(-7200),
+			Variables:  []model.Variable{v},
+			Guarantees: []model.Guarantee{{Name: "gt", Constraint: "availability >= 99.9"}},
+		},
+	}
+	retriever := MemoryRetriever{
+		"ok":       newValues("", t0, []m{{-4000, 0}, {-60, 999}, {0, 997}}),
+		"requests": newValues("", t0, []m{{-4000, 1000}, {-60, 1000}, {0, 1000}}),
+	}
+
+	ma := New(retriever.Retrieve(), Aggregate).Initialize(&a)
+	values := ma.GetValues(a.Details.Guarantees[0], []string{"availability"}, T.T(0))
+	if len(values) != 1 {
+		t.Fatalf("Unexpected values. Expected: 1. Actual: %v", values)
+	}
+	if actual := values[0]["availability"]; actual.Value != 99.8 {
+		t.Errorf("Unexpected availability. Expected: 99.8. Actual: %v", actual)
+	}
+
+	result, err := assessment.EvaluateAgreement(context.Background(), &a, ma, T.T(0), nil)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(result.Violated) != 1 {
+		t.Errorf("Unexpected violated terms. Expected: 1. Actual: %v", result.Violated)
+	}
+}
+
+func TestExpandRatios(t *testing.T) {
+	t0 := time.Now()
+	T := utils.Timeline{T0: t0}
+	v := model.Variable{
+		Name: "errors",
+		Ratio: &model.Ratio{
+			Good:  model.RatioMetric{Metric: "failed", Aggregation: &model.Aggregation{Type: model.COUNT, Window: 60}},
+			Total: model.RatioMetric{Metric: "requests"},
+		},
+	}
+	plain := newVar("plain")
+	items := expandRatios([]monitor.RetrievalItem{
+		{Var: plain, From: T.T(-10), To: T.T(0)},
+		{Var: v, From: T.T(-10), To: T.T(0)},
+	})
+	if len(items) != 3 {
+		t.Fatalf("Unexpected items. Expected: 3. Actual: %v", items)
+	}
+	good, total := v.RatioVariables()
+	if items[0].Var != plain {
+		t.Errorf("Unexpected item: %v", items[0])
+	}
+	if items[1].Var != good || items[1].From != T.T(-60) || items[1].To != T.T(0) {
+		t.Errorf("Unexpected item of good metric: %v", items[1])
+	}
+	if items[2].Var != total || items[2].From != T.T(-10) || items[2].To != T.T(0) {
+		t.Errorf("Unexpected item of total metric: %v", items[2])
+	}
+}
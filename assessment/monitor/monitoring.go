@@ -24,6 +24,8 @@ package monitor
 import (
 	assessment_model "SLALite/assessment/model"
 	"SLALite/model"
+	"context"
+	"fmt"
 	"time"
 )
 
@@ -35,6 +37,9 @@ type MonitoringAdapter interface {
 	Initialize(a *model.Agreement) MonitoringAdapter
 
 	// GetValues retrieve the metrics corresponding to the variables found in a guarantee
+	//
+	// If the guarantee is evaluated for a scope member (see model.Guarantee.Member),
+	// the metrics of that member must be returned.
 	GetValues(gt model.Guarantee, vars []string, to time.Time) assessment_model.GuaranteeData
 }
 
@@ -46,6 +51,9 @@ type RetrievalItem struct {
 	Var       model.Variable
 	From      time.Time
 	To        time.Time
+	// Member is the scope member whose values must be retrieved, if any
+	// (see model.Guarantee.Member)
+	Member string
 }
 
 // EarlyRetriever is implemented by adapters that want to (and can) retrieve
@@ -53,3 +61,64 @@ type RetrievalItem struct {
 type EarlyRetriever interface {
 	RetrieveAllValues(items []RetrievalItem) []assessment_model.GuaranteeData
 }
+
+// ContextMonitoringAdapter is implemented by the adapters whose retrieval can be
+// cancelled through a context and that are able to report retrieval failures.
+type ContextMonitoringAdapter interface {
+	MonitoringAdapter
+
+	// GetValuesContext retrieves the metrics corresponding to the variables found in
+	// a guarantee. It must return as soon as possible after ctx is done.
+	//
+	// An error is returned if the values could not be retrieved; an empty
+	// GuaranteeData with a nil error means that there is no data.
+	GetValuesContext(ctx context.Context, gt model.Guarantee, vars []string, to time.Time) (assessment_model.GuaranteeData, error)
+}
+
+// WithContext returns ma as a ContextMonitoringAdapter.
+//
+// If ma does not implement ContextMonitoringAdapter, it is wrapped in an adapter
+// whose GetValuesContext calls ma.GetValues, only checking ctx before retrieval.
+func WithContext(ma MonitoringAdapter) ContextMonitoringAdapter {
+	if cma, ok := ma.(ContextMonitoringAdapter); ok {
+		return cma
+	}
+	return contextAdapter{ma}
+}
+
+// contextAdapter is the compatibility wrapper returned by WithContext
+type contextAdapter struct {
+	MonitoringAdapter
+}
+
+// Initialize implements MonitoringAdapter.Initialize
+func (ca contextAdapter) Initialize(a *model.Agreement) MonitoringAdapter {
+	return contextAdapter{ca.MonitoringAdapter.Initialize(a)}
+}
+
+// GetValuesContext implements ContextMonitoringAdapter.GetValuesContext
+func (ca contextAdapter) GetValuesContext(ctx context.Context, gt model.Guarantee,
+	vars []string, to time.Time) (assessment_model.GuaranteeData, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ca.GetValues(gt, vars, to), nil
+}
+
+// RetrievalError is the error returned by the assessment when the values of
+// a guarantee term could not be retrieved from monitoring.
+type RetrievalError struct {
+	Guarantee string
+	Err       error
+}
+
+func (e *RetrievalError) Error() string {
+	return fmt.Sprintf("Error retrieving values of guarantee %s: %s", e.Guarantee, e.Err.Error())
+}
+
+// IsRetrievalError checks that the err is a RetrievalError
+func IsRetrievalError(err error) bool {
+	_, ok := err.(*RetrievalError)
+	return ok
+}
@@ -40,3 +40,21 @@ func (n LogNotifier) NotifyViolations(agreement *model.Agreement, result *assess
 		}
 	}
 }
+
+// NotifyWarnings implements WarningNotifier interface
+func (n LogNotifier) NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result) {
+	log.Info("Warning of agreement: " + agreement.Id)
+	for k, v := range result.Warned {
+		for _, values := range v {
+			log.Infof("Warning threshold of guarantee %s of agreement %s breached with values %v", k, agreement.Id, values)
+		}
+	}
+}
+
+// NotifyRecoveries implements RecoveryNotifier interface
+func (n LogNotifier) NotifyRecoveries(agreement *model.Agreement, incidents []model.Incident) {
+	for _, i := range incidents {
+		log.Infof("Guarantee %s of agreement %s recovered at %s after %.0f seconds",
+			i.Guarantee, agreement.Id, i.End, i.Duration)
+	}
+}
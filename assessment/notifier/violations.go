@@ -23,3 +23,21 @@ import (
 type ViolationNotifier interface {
 	NotifyViolations(agreement *model.Agreement, result *assessment_model.Result)
 }
+
+// WarningNotifier is implemented by the notifiers that also want to be
+// notified about the guarantee terms whose warning threshold was breached.
+//
+// The assessment process checks if its ViolationNotifier implements this
+// interface; warnings are delivered on result.Warned.
+type WarningNotifier interface {
+	NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result)
+}
+
+// RecoveryNotifier is implemented by the notifiers that also want to be
+// notified when a violated guarantee term fulfills its constraint again.
+//
+// The assessment process checks if its ViolationNotifier implements this
+// interface; incidents contains the incidents closed on the assessment.
+type RecoveryNotifier interface {
+	NotifyRecoveries(agreement *model.Agreement, incidents []model.Incident)
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package assessment
+
+import (
+	"SLALite/assessment/monitor"
+	"SLALite/assessment/notifier"
+	"SLALite/model"
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPeriod is the assessment period of the agreements without Schedule, if
+// the Period of the Config of a Scheduler is not set.
+const DefaultPeriod = time.Minute
+
+// minWait is the minimum time a Scheduler waits between two assessment cycles.
+const minWait = time.Second
+
+// ErrAssessmentRunning is returned by Scheduler.Reassess if the agreement is
+// being assessed
+var ErrAssessmentRunning = errors.New("The agreement is being assessed")
+
+/*
+Scheduler assesses each active agreement when it is due, according to the
+Schedule in its details or, if not set, to the Period of the Config.
+
+The agreements are looked up in the repository when the next agreement is due,
+and at least every Period, so a new agreement is assessed for the first time
+within a Period. The due agreements are assessed as in AssessActiveAgreements;
+a cycle starts after the previous one has finished. An agreement whose previous
+assessment is still running (e.g., it timed out but the monitoring adapter was
+not interrupted) or re-assessed (see Reassess) is skipped until it finishes.
+
+Usage:
+
+	s := assessment.NewScheduler(repo, ma, not, cfg)
+	go s.Run(ctx)
+*/
+type Scheduler struct {
+	repo model.IRepository
+	ma   monitor.MonitoringAdapter
+	not  notifier.ViolationNotifier
+	cfg  Config
+
+	// assessed is the time of the last assessment of each agreement by the
+	// scheduler, so that agreements whose assessment did not update their
+	// LastExecution (e.g., stopped or timed out) are not retried until due
+	assessed map[string]time.Time
+
+	// running is the set of agreements being assessed
+	running *inFlight
+}
+
+// NewScheduler returns a Scheduler that assesses the active agreements in repo.
+func NewScheduler(repo model.IRepository, ma monitor.MonitoringAdapter,
+	not notifier.ViolationNotifier, cfg Config) *Scheduler {
+
+	if cfg.Period < time.Second {
+		cfg.Period = DefaultPeriod
+	}
+	return &Scheduler{
+		repo:     repo,
+		ma:       ma,
+		not:      not,
+		cfg:      cfg,
+		assessed: map[string]time.Time{},
+		running:  newInFlight(),
+	}
+}
+
+// Run assesses the due agreements until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		next := s.AssessDueAgreements(ctx, time.Now())
+		wait := time.Until(next)
+		if wait < minWait {
+			wait = minWait
+		} else if wait > s.cfg.Period {
+			wait = s.cfg.Period
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// AssessDueAgreements assesses the active agreements that are due at now, and
+// returns the time the next agreement is due.
+func (s *Scheduler) AssessDueAgreements(ctx context.Context, now time.Time) time.Time {
+	agreements, err := s.repo.GetAgreementsByState(model.STARTED, model.STOPPED)
+	if err != nil {
+		log.Errorf("Error getting active agreements: %s", err.Error())
+		return now.Add(s.cfg.Period)
+	}
+
+	assessed := make(map[string]time.Time, len(agreements))
+	due := make(model.Agreements, 0, len(agreements))
+	next := now.Add(s.cfg.Period)
+	for _, a := range agreements {
+		last := s.assessed[a.Id]
+		if a.Assessment.LastExecution.After(last) {
+			last = a.Assessment.LastExecution
+		}
+		if t := s.next(a, last); last.IsZero() || !now.Before(t) {
+			due = append(due, a)
+			last = now
+		}
+		assessed[a.Id] = last
+		if t := s.next(a, last); t.Before(next) {
+			next = t
+		}
+	}
+	s.assessed = assessed
+
+	log.Debugf("AssessDueAgreements(). %d of %d agreements to evaluate", len(due), len(agreements))
+	assessAgreements(ctx, s.repo, s.ma, s.not, s.cfg, due, s.running)
+	return next
+}
+
+// Reassess re-assesses an agreement over the past interval [from, to] (see
+// ReassessAgreement) in a new goroutine, with the monitoring adapter and the
+// Config of the scheduler; done is called with the outcome when it finishes.
+//
+// An agreement is never assessed twice at once: if the agreement is being assessed,
+// periodically or by another re-assessment, nothing is started and
+// ErrAssessmentRunning is returned; while the re-assessment runs, the periodic
+// assessment skips the agreement. The re-assessment and the periodic assessment may
+// write to the repository at the same time, so the repository must be thread-safe.
+func (s *Scheduler) Reassess(ctx context.Context, a model.Agreement, from, to time.Time,
+	done func([]model.Violation, error)) error {
+
+	if !s.running.add(a.Id) {
+		return ErrAssessmentRunning
+	}
+	go func() {
+		violations, err := ReassessAgreement(ctx, s.repo, a, s.ma, from, to, s.cfg)
+		s.running.remove(a.Id)
+		done(violations, err)
+	}()
+	return nil
+}
+
+// ReassessmentSteps returns the number of assessments of the re-assessment of an
+// agreement over [from, to] (see Reassess), counting up to limit + 1 so that a long
+// interval is not stepped through.
+func (s *Scheduler) ReassessmentSteps(a model.Agreement, from, to time.Time, limit int) int {
+	steps := 0
+	for now := from; now.Before(to) && steps <= limit; steps++ {
+		now = nextReassessment(a, now, to, s.cfg.Period)
+	}
+	return steps
+}
+
+// next returns the time an agreement last assessed at "last" is due (see nextAssessment)
+func (s *Scheduler) next(a model.Agreement, last time.Time) time.Time {
+	return nextAssessment(a, last, s.cfg.Period)
+}
+
+// nextAssessment returns the time an agreement last assessed at "last" is due,
+// according to its Schedule or, if empty, to period. An invalid Schedule is ignored.
+func nextAssessment(a model.Agreement, last time.Time, period time.Duration) time.Time {
+	if a.Details.Schedule != "" {
+		if next, err := a.Details.Schedule.Next(last); err == nil {
+			return next
+		}
+	}
+	return last.Add(period)
+}
@@ -21,6 +21,8 @@ package ditas
 import (
 	"SLALite/assessment/monitor"
 	"SLALite/model"
+	"context"
+	"fmt"
 	"strings"
 	"time"
 
@@ -64,12 +66,26 @@ func NewDataAnalyticsAdapter(analyticsBaseUrl, vdcID, infraID string, testingCon
 	}
 }
 
-// Initialize the monitoring retrieval for one evaluation of the agreement
-//
-// A new MonitoringAdapter, copy of current adapter, must be returned
+// Retrieve implements genericadapter.Retrieve. Retrieval errors are logged.
 func (d DataAnalyticsAdapter) Retrieve(agreement model.Agreement,
 	items []monitor.RetrievalItem) map[model.Variable][]model.MetricValue {
+	result, err := d.RetrieveContext(context.Background(), agreement, items)
+	if err != nil {
+		log.WithError(err).Errorf("Error retrieving values of agreement %s", agreement.Id)
+	}
+	return result
+}
+
+// RetrieveContext implements genericadapter.RetrieveContext.
+//
+// The requests to data analytics are cancelled when ctx is done. If a request fails,
+// the rest of items are still retrieved and the first error is returned along with
+// the values that could be retrieved. The values of a scope member are filtered by
+// the member query parameter.
+func (d DataAnalyticsAdapter) RetrieveContext(ctx context.Context, agreement model.Agreement,
+	items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error) {
 	result := make(map[model.Variable][]model.MetricValue)
+	var firstErr error
 
 	useTesting := d.TestingConfiguration.Enabled && agreement.Id == d.TestingConfiguration.MethodID
 	for _, item := range items {
@@ -84,47 +100,53 @@ func (d DataAnalyticsAdapter) Retrieve(agreement model.Agreement,
 				},
 			}
 		} else {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
 			metrics := make([]DataAnalyticsMetrics, 0)
-			res, err := d.Client.R().SetQueryParams(map[string]string{
+			params := map[string]string{
 				"operationID": agreement.Id,
 				"name":        item.Var.Metric,
 				"startTime":   item.From.Format(time.RFC3339),
 				"endTime":     item.To.Format(time.RFC3339),
-			}).SetPathParams(map[string]string{
+			}
+			if item.Member != "" {
+				params["member"] = item.Member
+			}
+			res, err := d.Client.R().SetContext(ctx).SetQueryParams(params).SetPathParams(map[string]string{
 				"infraId": d.VdcID,
 			}).SetResult(&metrics).Get(d.AnalyticsBaseUrl)
+			if err == nil && res.IsError() {
+				err = fmt.Errorf("Data analytics returned status %d for metric %s", res.StatusCode(), item.Var.Metric)
+			}
 			if err != nil {
 				log.WithError(err).Errorf("Error getting values for metric %s", item.Var.Metric)
+				if firstErr == nil {
+					firstErr = err
+				}
 			} else {
-				if !res.IsError() {
-					currentMetrics, ok := result[item.Var]
-					if !ok {
-						currentMetrics = make([]model.MetricValue, 0, len(metrics))
-						log.Printf("len(metrics): %d", len(metrics))
-						log.Printf("%#v", metrics)
-					}
-					for _, metric := range metrics {
-						metricTime, err := time.Parse(time.RFC3339, metric.DataAnalyticsMeter.Timestamp)
-						if err != nil {
-							log.WithError(err).Errorf("Error parsing timestamp %s for metric %s", metric.DataAnalyticsMeter.Timestamp, item.Var.Metric)
-						} else {
-							currentMetrics = append(currentMetrics, model.MetricValue{
-								Key:      item.Var.Metric,
-								Value:    metric.DataAnalyticsMeter.Value,
-								DateTime: metricTime,
-							})
-							log.Printf("Retrieve.Result key: %s", item.Var.Metric)
-							log.Printf("Retrieve.Result value: %v", metric.DataAnalyticsMeter.Value)
-							log.Printf("Retrieve.Result key: %s", metricTime)
-						}
+				currentMetrics, ok := result[item.Var]
+				if !ok {
+					currentMetrics = make([]model.MetricValue, 0, len(metrics))
+				}
+				log.Debugf("Retrieved %d values of metric %s", len(metrics), item.Var.Metric)
+				for _, metric := range metrics {
+					metricTime, err := time.Parse(time.RFC3339, metric.DataAnalyticsMeter.Timestamp)
+					if err != nil {
+						log.WithError(err).Errorf("Error parsing timestamp %s for metric %s", metric.DataAnalyticsMeter.Timestamp, item.Var.Metric)
+					} else {
+						currentMetrics = append(currentMetrics, model.MetricValue{
+							Key:      item.Var.Metric,
+							Value:    metric.DataAnalyticsMeter.Value,
+							DateTime: metricTime,
+						})
 					}
-					result[item.Var] = currentMetrics
 				}
+				result[item.Var] = currentMetrics
 			}
 		}
 	}
-	log.Printf("Retrieve.result %#v", result)
-	return result
+	return result, firstErr
 }
 
 func (d *DataAnalyticsAdapter) Process(v model.Variable, values []model.MetricValue) []model.MetricValue {
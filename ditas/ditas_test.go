@@ -25,10 +25,12 @@ import (
 	"SLALite/assessment/monitor/genericadapter"
 	"SLALite/assessment/monitor/simpleadapter"
 	"SLALite/model"
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -237,6 +239,27 @@ func TestDitasMonitoringAdapter(t *testing.T) {
 
 }
 
+func TestDitasMonitoringAdapterCancelled(t *testing.T) {
+	bp, err := blueprint.ReadBlueprint("resources/concrete_blueprint_doctor.json")
+	if err != nil {
+		t.Fatalf("Error reading blueprint: %s", err.Error())
+	}
+	slas, _ := CreateAgreements(bp)
+	sla := slas[0]
+
+	da := NewDataAnalyticsAdapter(dataAnalyticsURL, "vdc1", "infra1", TestingConfiguration{
+		Enabled: false,
+	}, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now := time.Now()
+	_, err = da.RetrieveContext(ctx, sla, getMonitoringItems(sla, now, now))
+	if err == nil {
+		t.Errorf("Expected error retrieving values with a cancelled context")
+	}
+}
+
 func TestNotifier(t *testing.T) {
 	bp, err := blueprint.ReadBlueprint("resources/concrete_blueprint_doctor.json")
 	if err != nil {
@@ -266,7 +289,7 @@ func TestNotifier(t *testing.T) {
 		m1,
 	})
 
-	result := assessment.AssessAgreement(&slas[0], adapter, time.Now())
+	result := assessment.AssessAgreement(context.Background(), &slas[0], adapter, time.Now(), nil)
 	testNotifier.NotifyViolations(&slas[0], &result)
 
 	notViolations := testNotifier.Violations
@@ -325,3 +348,49 @@ func contains(key string, values []string) bool {
 func t_(second time.Duration) time.Time {
 	return t0.Add(time.Second * second)
 }
+
+func TestNotifierWithFunctions(t *testing.T) {
+	n := NewNotifier("VDC_2", DS4MUrl, TestingConfiguration{}, false)
+	values := []model.MetricValue{
+		{Key: "latency", Value: 150.0, DateTime: t_(0)},
+		{Key: "errors", Value: -10.0, DateTime: t_(0)},
+		{Key: "availability", Value: 99.0, DateTime: t_(0)},
+		{Key: "throughput", Value: 2, DateTime: t_(0)},
+	}
+	expected := map[string][]string{
+		"between(latency, -10, 100) && between(availability, 90, 100)":  {"latency"},
+		"abs(errors) < 5 && abs(latency) < 200":                         {"errors"},
+		"max(latency, throughput) < 100 && min(availability, 95) >= 95": {"latency", "throughput"},
+		"max(errors, 5) >= 5 && abs(latency - 100) < 10":                {"latency"},
+		"isnull(errors) || isnull(missing) || errors > 0":               {"errors"},
+		"!isnull(availability) && (latency < 100 || isnull(latency))":   {"availability", "latency"},
+		"hour() < 8 && throughput > 5":                                  {"throughput"},
+	}
+	for constraint, keys := range expected {
+		result := assessment_model.Result{
+			Violated: map[string]assessment_model.EvaluationGtResult{
+				"gt": {
+					Violations: []model.Violation{
+						{
+							AgreementId: "method",
+							Constraint:  constraint,
+							Values:      values,
+						},
+					},
+				},
+			},
+		}
+		violations := n.filterValues("method", &result)
+		if len(violations) != 1 {
+			t.Errorf("Unexpected violations of %s. Expected: %v. Actual: %v", constraint, keys, violations)
+			continue
+		}
+		actual := make([]string, 0)
+		for _, v := range violations[0].Metrics {
+			actual = append(actual, v.Key)
+		}
+		if !reflect.DeepEqual(actual, keys) {
+			t.Errorf("Unexpected violated metrics of %s. Expected: %v. Actual: %v", constraint, keys, actual)
+		}
+	}
+}
@@ -445,6 +445,6 @@ func Configure(repo model.IRepository) (monitor.MonitoringAdapter, notifier.Viol
 		}
 	}
 	da := NewDataAnalyticsAdapter(config.GetString(DataAnalyticsURLProperty), config.GetString(VDCIdPropery), config.GetString(InfrastructureIDProperty), testingConfig, debugHTTP)
-	adapter := genericadapter.New(da.Retrieve, da.Process)
+	adapter := genericadapter.NewContext(da.RetrieveContext, da.Process)
 	return adapter, NewNotifier(vdcID, vdmURL, testingConfig, debugHTTP), nil
 }
@@ -90,6 +90,128 @@ func evaluate(comparator string, thresholdIf interface{}, valueIf interface{}) (
 	return false, errors.New("Comparator not supported: " + comparator)
 }
 
+// evaluateCall calls a function of an expression with the arguments of the call
+// (see callArgs), replacing the variables with their values
+func evaluateCall(f govaluate.ExpressionFunction, args []interface{}, values map[string]interface{}) (interface{}, error) {
+	params := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		if variable, ok := arg.(string); ok {
+			value, err := toFloat(values[variable])
+			if err != nil {
+				return nil, errors.New("Can't parse value: " + err.Error())
+			}
+			arg = value
+		}
+		params = append(params, arg)
+	}
+	return f(params...)
+}
+
+// callEnd returns the index of the parenthesis that closes the one at
+// tokens[start], or -1 if tokens[start] is not an opening parenthesis or it is
+// not closed.
+func callEnd(tokens []govaluate.ExpressionToken, start int) int {
+	if start >= len(tokens) || tokens[start].Kind != govaluate.CLAUSE {
+		return -1
+	}
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].Kind {
+		case govaluate.CLAUSE:
+			depth++
+		case govaluate.CLAUSE_CLOSE:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// callArgs returns the arguments of a function call, given the tokens between its
+// parentheses: the name (string) of each variable and the value (float64) of each
+// number. ok is false if any argument is not a variable nor a number.
+func callArgs(tokens []govaluate.ExpressionToken) (args []interface{}, ok bool) {
+	for len(tokens) > 0 {
+		if tokens[0].Kind == govaluate.VARIABLE {
+			args = append(args, tokens[0].Value.(string))
+			tokens = tokens[1:]
+		} else {
+			var number float64
+			if number, tokens, ok = numberArg(tokens); !ok {
+				return nil, false
+			}
+			args = append(args, number)
+		}
+		if len(tokens) > 0 {
+			if tokens[0].Kind != govaluate.SEPARATOR {
+				return nil, false
+			}
+			tokens = tokens[1:]
+		}
+	}
+	return args, true
+}
+
+// callCheck returns the variables of a function call, and the check of their
+// values, given the tokens from the function name on and the index of the closing
+// parenthesis. between(<arguments>) must be true, and abs, min and max must meet the
+// comparison that follows the call, e.g. abs(latency) < 200. check is nil if the
+// call cannot be checked: other functions (e.g. isnull(latency)), other comparisons,
+// or arguments that are not variables nor numbers (e.g. abs(latency - 100) < 10).
+func callCheck(name string, tokens []govaluate.ExpressionToken, end int) (
+	variables []string, check func(values map[string]interface{}) (bool, error)) {
+
+	for _, token := range tokens[1:end] {
+		if token.Kind == govaluate.VARIABLE {
+			variables = append(variables, token.Value.(string))
+		}
+	}
+	args, ok := callArgs(tokens[2:end])
+	if !ok {
+		return variables, nil
+	}
+	f := tokens[0].Value.(govaluate.ExpressionFunction)
+	switch name {
+	case "between":
+		check = func(values map[string]interface{}) (bool, error) {
+			result, err := evaluateCall(f, args, values)
+			if err != nil {
+				return false, err
+			}
+			return result == true, nil
+		}
+	case "abs", "min", "max":
+		if end+2 < len(tokens) && tokens[end+1].Kind == govaluate.COMPARATOR && tokens[end+2].Kind == govaluate.NUMERIC {
+			comparator := tokens[end+1].Value.(string)
+			threshold := tokens[end+2].Value
+			check = func(values map[string]interface{}) (bool, error) {
+				result, err := evaluateCall(f, args, values)
+				if err != nil {
+					return false, err
+				}
+				return evaluate(comparator, threshold, result)
+			}
+		}
+	}
+	return variables, check
+}
+
+// numberArg parses a (possibly negative) number at the start of tokens, returning
+// the remaining tokens
+func numberArg(tokens []govaluate.ExpressionToken) (float64, []govaluate.ExpressionToken, bool) {
+	sign := 1.0
+	if len(tokens) > 0 && tokens[0].Kind == govaluate.PREFIX && tokens[0].Value == "-" {
+		sign = -1
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 || tokens[0].Kind != govaluate.NUMERIC {
+		return 0, tokens, false
+	}
+	return sign * tokens[0].Value.(float64), tokens[1:], true
+}
+
 // filterValues will filter those metric values that don't meet its threshold in the guarantee
 // and may be the responsibles for the failure of the evaluation and so, of the violation.
 func (n *Notifier) filterValues(methodID string, result *assessment_model.Result) []Violation {
@@ -110,33 +232,71 @@ func (n *Notifier) filterValues(methodID string, result *assessment_model.Result
 			}
 
 			// Make a govaluate expression from the guarantee to re-evaluate it
-			expression, err := govaluate.NewEvaluableExpression(violation.Constraint)
+			expression, err := model.NewExpression(violation.Constraint)
 			if err == nil {
 				violationInformation, ok := violationMap[violation.AgreementId]
 				if !ok {
 					violationInformation = make([]model.MetricValue, 0)
 				}
 				tokens := expression.Tokens()
-				// Go over tokens to find expressions of type <variable> <operator> <value> i.e. availability >= 90
-				for i, token := range tokens {
+				reported := make(map[string]bool)
+				// Go over tokens to find expressions of type <variable> <operator> <value> i.e. availability >= 90,
+				// or function calls i.e. between(availability, 90, 100) or abs(latency) < 200 (see callCheck).
+				// The values of the variables of the calls that cannot be checked are sent unfiltered.
+				for i := 0; i < len(tokens); i++ {
+					token := tokens[i]
+					var variables []string
+					var check func(values map[string]interface{}) (bool, error)
 					if token.Kind == govaluate.VARIABLE && (i < len(tokens)-2) && (tokens[i+1].Kind == govaluate.COMPARATOR && tokens[i+2].Kind == govaluate.NUMERIC) {
 						variable := token.Value.(string)
 						comparator := tokens[i+1].Value.(string)
 						threshold := tokens[i+2].Value
-						value, found := valueMap[variable]
-						if found {
-							// Evaluate the comparison to see if it violates the threshold that was defined
-							assessed, err := evaluate(comparator, threshold, value.Value)
-							if err == nil && !assessed {
-								// If so, add it to the list of values that will be sent
-								violationInformation = append(violationInformation, value)
-							} else {
-								if err != nil {
-									log.Errorf("Error assessing expression %s: %s", violation.Constraint, err.Error())
-								}
-							}
+						variables = []string{variable}
+						check = func(values map[string]interface{}) (bool, error) {
+							return evaluate(comparator, threshold, values[variable])
+						}
+					} else if name := expression.FunctionName(token); name != "" {
+						end := callEnd(tokens, i+1)
+						if end < 0 {
+							break
+						}
+						variables, check = callCheck(name, tokens[i:], end-i)
+						i = end
+					}
+					if len(variables) == 0 {
+						continue
+					}
+
+					values := make(map[string]interface{}, len(variables))
+					found := true
+					for _, variable := range variables {
+						if value, ok := valueMap[variable]; ok {
+							values[variable] = value.Value
 						} else {
-							log.Errorf("Can't find value for variable %s", variable)
+							found = false
+							if check != nil {
+								log.Errorf("Can't find value for variable %s", variable)
+							}
+						}
+					}
+					if check != nil {
+						if !found {
+							continue
+						}
+						// Evaluate the comparison to see if it violates the threshold that was defined
+						assessed, err := check(values)
+						if err != nil {
+							log.Errorf("Error assessing expression %s: %s", violation.Constraint, err.Error())
+						}
+						if err != nil || assessed {
+							continue
+						}
+					}
+					// If so, or if it cannot be checked, add the values to the list of values that will be sent
+					for _, variable := range variables {
+						if value, ok := valueMap[variable]; ok && !reported[variable] {
+							reported[variable] = true
+							violationInformation = append(violationInformation, value)
 						}
 					}
 				}
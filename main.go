@@ -17,14 +17,13 @@ package main
 
 import (
 	"SLALite/assessment"
-	"SLALite/assessment/monitor"
-	"SLALite/assessment/notifier"
 	"SLALite/ditas"
 	"SLALite/model"
 	"SLALite/repositories/memrepository"
 	"SLALite/repositories/mongodb"
 	"SLALite/repositories/validation"
 	"SLALite/utils"
+	"context"
 	"flag"
 	"strconv"
 	"strings"
@@ -52,8 +51,13 @@ func main() {
 	logMainConfig(config)
 
 	singlefile := config.GetBool(utils.SingleFilePropertyName)
-	checkPeriod := config.GetDuration(utils.CheckPeriodPropertyName)
 	repoType := config.GetString(utils.RepositoryTypePropertyName)
+	assessmentCfg := assessment.Config{
+		Workers:     config.GetInt(utils.AssessmentWorkersPropertyName),
+		Timeout:     config.GetDuration(utils.AssessmentTimeoutPropertyName) * time.Second,
+		Period:      config.GetDuration(utils.CheckPeriodPropertyName) * time.Second,
+		ExternalIDs: config.GetBool(utils.ExternalIDsPropertyName),
+	}
 
 	utils.AddTrustedCAs(config)
 
@@ -77,10 +81,11 @@ func main() {
 	validater := model.NewDefaultValidator(config.GetBool(utils.ExternalIDsPropertyName), true)
 	repo, _ = validation.New(repo, validater)
 	if repo != nil {
-		a, _ := NewApp(config, repo, validater)
 		adapter, notifier, err := ditas.Configure(repo)
 		if err == nil {
-			go createValidationThread(repo, adapter, notifier, checkPeriod)
+			scheduler := assessment.NewScheduler(repo, adapter, notifier, assessmentCfg)
+			a, _ := NewApp(config, repo, validater, scheduler)
+			go scheduler.Run(context.Background())
 			a.Run()
 		}
 	}
@@ -100,6 +105,8 @@ func createMainConfig(file *string, paths *string, basename *string) *viper.Vipe
 	config.SetDefault(utils.CheckPeriodPropertyName, utils.DefaultCheckPeriod)
 	config.SetDefault(utils.RepositoryTypePropertyName, utils.DefaultRepositoryType)
 	config.SetDefault(utils.ExternalIDsPropertyName, utils.DefaultExternalIDs)
+	config.SetDefault(utils.AssessmentWorkersPropertyName, utils.DefaultAssessmentWorkers)
+	config.SetDefault(utils.AssessmentTimeoutPropertyName, utils.DefaultAssessmentTimeout)
 
 	if *file != "" {
 		config.SetConfigFile(*file)
@@ -123,13 +130,17 @@ func logMainConfig(config *viper.Viper) {
 	checkPeriod := config.GetDuration(utils.CheckPeriodPropertyName)
 	repoType := config.GetString(utils.RepositoryTypePropertyName)
 	externalIDs := config.GetBool(utils.ExternalIDsPropertyName)
+	workers := config.GetInt(utils.AssessmentWorkersPropertyName)
+	timeout := config.GetDuration(utils.AssessmentTimeoutPropertyName)
 
 	log.Infof("SLALite initialization\n"+
 		"\tConfigfile: %s\n"+
 		"\tRepository type: %s\n"+
 		"\tExternal IDs: %v\n"+
-		"\tCheck period:%d\n",
-		config.ConfigFileUsed(), repoType, externalIDs, checkPeriod)
+		"\tCheck period:%d\n"+
+		"\tAssessment workers:%d\n"+
+		"\tAssessment timeout:%v\n",
+		config.ConfigFileUsed(), repoType, externalIDs, checkPeriod, workers, timeout*time.Second)
 
 	caPath := config.GetString(utils.CAPathPropertyName)
 	if caPath != "" {
@@ -137,18 +148,6 @@ func logMainConfig(config *viper.Viper) {
 	}
 }
 
-func createValidationThread(repo model.IRepository, ma monitor.MonitoringAdapter,
-	not notifier.ViolationNotifier, checkPeriod time.Duration) {
-
-	ticker := time.NewTicker(checkPeriod * time.Second)
-
-	for {
-		<-ticker.C
-		assessment.AssessActiveAgreements(repo, ma, not)
-	}
-
-}
-
 func validateProviders(repo model.IRepository) {
 	providers, err := repo.GetAllProviders()
 
@@ -16,10 +16,15 @@ limitations under the License.
 package main
 
 import (
+	"SLALite/assessment"
+	amodel "SLALite/assessment/model"
+	"SLALite/assessment/monitor"
+	"SLALite/assessment/monitor/genericadapter"
 	"SLALite/model"
 	"SLALite/utils"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
@@ -66,7 +71,7 @@ func TestMain(m *testing.M) {
 		if err != nil {
 			log.Fatalf("Error creating initial state: %v", err)
 		}
-		a, _ = NewApp(viper.New(), repo, model.NewDefaultValidator(false, true))
+		a, _ = NewApp(viper.New(), repo, model.NewDefaultValidator(false, true), nil)
 	} else {
 		log.Fatal("Error initializing repository")
 	}
@@ -538,6 +543,580 @@ func testAgreementNotEscaped(t *testing.T) {
 	}
 }
 
+/********************************************************************
+*****************VIOLATIONS*****************************************
+********************************************************************/
+
+func TestViolations(t *testing.T) {
+	av := createAgreement("av01", p1, c2, "Agreement with violations", nil)
+	av.Assessment.SetGuarantee("TestGuarantee", model.AssessmentGuarantee{
+		Compliance: &model.Compliance{
+			Current: &model.CompliancePeriod{Start: time.Now(), Points: 4, FailedPoints: 1, Attainment: 0.75},
+		},
+	})
+	av.Assessment.SetStatus(model.VIOLATED, time.Now())
+	av.Assessment.SetGuarantee("BrokenGuarantee", model.AssessmentGuarantee{
+		Error: &model.EvaluationError{Message: "No parameter 'n' found.", Datetime: time.Now()},
+	})
+	if _, err := repo.CreateAgreement(&av); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+	for i, gt := range []string{"gt1", "gt1", "gt2"} {
+		v := model.Violation{
+			Id:          "v0" + strconv.Itoa(i),
+			AgreementId: av.Id,
+			Guarantee:   gt,
+			Datetime:    time.Now().Add(time.Duration(i-10) * time.Minute),
+			Constraint:  "test_value > 10",
+			Values:      []model.MetricValue{{Key: "test_value", Value: 5, DateTime: time.Now()}},
+		}
+		if _, err := repo.CreateViolation(&v); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+		p := model.Penalty{
+			Id:          "pe0" + strconv.Itoa(i),
+			AgreementId: av.Id,
+			Guarantee:   gt,
+			ViolationId: v.Id,
+			Datetime:    v.Datetime,
+			Definition:  model.PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+		}
+		if _, err := repo.CreatePenalty(&p); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+	}
+	i1 := model.Incident{
+		Id:          "i01",
+		AgreementId: av.Id,
+		Guarantee:   "gt1",
+		Start:       time.Now().Add(-10 * time.Minute),
+		Violations:  []string{"v00", "v01"},
+	}
+	i1.Close(time.Now().Add(-8 * time.Minute))
+	i2 := model.Incident{
+		Id:          "i02",
+		AgreementId: av.Id,
+		Guarantee:   "gt2",
+		Start:       time.Now().Add(-8 * time.Minute),
+		Violations:  []string{"v02"},
+	}
+	for _, i := range []*model.Incident{&i1, &i2} {
+		if _, err := repo.CreateIncident(i); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+	}
+
+	t.Run("GetViolations", testGetViolations)
+	t.Run("GetViolationsWithFilters", testGetViolationsWithFilters)
+	t.Run("GetViolationsWithWrongFilters", testGetViolationsWithWrongFilters)
+	t.Run("GetViolationExists", testGetViolationExists)
+	t.Run("GetViolationNotExists", testGetViolationNotExists)
+	t.Run("GetAgreementViolations", testGetAgreementViolations)
+	t.Run("GetAgreementViolationsNotExists", testGetAgreementViolationsNotExists)
+	t.Run("GetAgreementPenalties", testGetAgreementPenalties)
+	t.Run("GetAgreementPenaltiesWithWrongFilters", testGetAgreementPenaltiesWithWrongFilters)
+	t.Run("GetAgreementPenaltiesNotExists", testGetAgreementPenaltiesNotExists)
+	t.Run("GetAgreementIncidents", testGetAgreementIncidents)
+	t.Run("GetAgreementIncidentsWithWrongFilters", testGetAgreementIncidentsWithWrongFilters)
+	t.Run("GetAgreementIncidentsNotExists", testGetAgreementIncidentsNotExists)
+	t.Run("GetAgreementCompliance", testGetAgreementCompliance)
+	t.Run("GetAgreementComplianceWithWrongPeriod", testGetAgreementComplianceWithWrongPeriod)
+	t.Run("GetAgreementErrors", testGetAgreementErrors)
+	t.Run("GetAgreementsByStatus", testGetAgreementsByStatus)
+	t.Run("GetAgreementsWithWrongStatus", testGetAgreementsWithWrongStatus)
+	t.Run("GetAgreementErrorsNotExists", testGetAgreementErrorsNotExists)
+}
+
+func testGetViolations(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/violations", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var violations model.Violations
+	_ = json.NewDecoder(res.Body).Decode(&violations)
+	if len(violations) != 3 {
+		t.Errorf("Expected 3 violations. Received: %v", violations)
+	}
+}
+
+func testGetViolationsWithFilters(t *testing.T) {
+	from := time.Now().Add(-9*time.Minute - 30*time.Second).Format(time.RFC3339)
+	req, _ := http.NewRequest("GET", "/violations?guarantee=gt1&provider=p01&from="+from, nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var violations model.Violations
+	_ = json.NewDecoder(res.Body).Decode(&violations)
+	if len(violations) != 1 || violations[0].Id != "v01" {
+		t.Errorf("Expected violation v01. Received: %v", violations)
+	}
+
+	req, _ = http.NewRequest("GET", "/violations?client=notexists", nil)
+	res = request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	_ = json.NewDecoder(res.Body).Decode(&violations)
+	if len(violations) != 0 {
+		t.Errorf("Expected 0 violations. Received: %v", violations)
+	}
+}
+
+func testGetViolationsWithWrongFilters(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/violations?from=yesterday", nil)
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testGetViolationExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/violations/v00", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var violation model.Violation
+	_ = json.NewDecoder(res.Body).Decode(&violation)
+	if violation.Id != "v00" {
+		t.Errorf("Expected: %v. Actual: %v", "v00", violation.Id)
+	}
+}
+
+func testGetViolationNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/violations/doesnotexist", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetAgreementViolations(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/violations?guarantee=gt2", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var violations model.Violations
+	_ = json.NewDecoder(res.Body).Decode(&violations)
+	if len(violations) != 1 || violations[0].Id != "v02" {
+		t.Errorf("Expected violation v02. Received: %v", violations)
+	}
+}
+
+func testGetAgreementViolationsNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/doesnotexist/violations", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetAgreementPenalties(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/penalties", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var penalties model.Penalties
+	_ = json.NewDecoder(res.Body).Decode(&penalties)
+	if len(penalties) != 3 {
+		t.Errorf("Expected 3 penalties. Received: %v", penalties)
+	}
+
+	req, _ = http.NewRequest("GET", "/agreements/av01/penalties?guarantee=gt1&violation=v01", nil)
+	res = request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	penalties = nil
+	_ = json.NewDecoder(res.Body).Decode(&penalties)
+	if len(penalties) != 1 || penalties[0].Id != "pe01" {
+		t.Errorf("Expected penalty pe01. Received: %v", penalties)
+	}
+}
+
+func testGetAgreementPenaltiesWithWrongFilters(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/penalties?to=tomorrow", nil)
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testGetAgreementPenaltiesNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/doesnotexist/penalties", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetAgreementIncidents(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/incidents", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var incidents model.Incidents
+	_ = json.NewDecoder(res.Body).Decode(&incidents)
+	if len(incidents) != 2 {
+		t.Errorf("Expected 2 incidents. Received: %v", incidents)
+	}
+
+	req, _ = http.NewRequest("GET", "/agreements/av01/incidents?open=true", nil)
+	res = request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	incidents = nil
+	_ = json.NewDecoder(res.Body).Decode(&incidents)
+	if len(incidents) != 1 || incidents[0].Id != "i02" {
+		t.Errorf("Expected incident i02. Received: %v", incidents)
+	}
+}
+
+func testGetAgreementIncidentsWithWrongFilters(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/incidents?open=maybe", nil)
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testGetAgreementIncidentsNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/doesnotexist/incidents", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetAgreementCompliance(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/compliance", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var compliance map[string]model.CompliancePeriod
+	_ = json.NewDecoder(res.Body).Decode(&compliance)
+	if p, ok := compliance["TestGuarantee"]; !ok || p.Attainment != 0.75 {
+		t.Errorf("Unexpected compliance: %v", compliance)
+	}
+
+	req, _ = http.NewRequest("GET", "/agreements/av01/compliance?period=previous", nil)
+	res = request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	compliance = nil
+	_ = json.NewDecoder(res.Body).Decode(&compliance)
+	if len(compliance) != 0 {
+		t.Errorf("Expected no compliance. Received: %v", compliance)
+	}
+}
+
+func testGetAgreementComplianceWithWrongPeriod(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/compliance?period=last", nil)
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testGetAgreementErrors(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/av01/errors", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var errs map[string]model.EvaluationError
+	_ = json.NewDecoder(res.Body).Decode(&errs)
+	if e, ok := errs["BrokenGuarantee"]; len(errs) != 1 || !ok || e.Message == "" {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+}
+
+func testGetAgreementsByStatus(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements?status=violated", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var agreements model.Agreements
+	_ = json.NewDecoder(res.Body).Decode(&agreements)
+	if len(agreements) != 1 || agreements[0].Id != "av01" {
+		t.Errorf("Expected only agreement av01. Received: %v", agreements)
+	}
+
+	req, _ = http.NewRequest("GET", "/agreements?status=fulfilled", nil)
+	res = request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	agreements = nil
+	_ = json.NewDecoder(res.Body).Decode(&agreements)
+	if len(agreements) != 0 {
+		t.Errorf("Expected no agreements. Received: %v", agreements)
+	}
+}
+
+func testGetAgreementsWithWrongStatus(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements?status=ok", nil)
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testGetAgreementErrorsNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/doesnotexist/errors", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func TestEvaluate(t *testing.T) {
+	ae := createAgreement("ae01", p1, c2, "Agreement to evaluate", nil)
+	if _, err := repo.CreateAgreement(&ae); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+
+	t.Run("Evaluate", testEvaluate)
+	t.Run("EvaluateById", testEvaluateByID)
+	t.Run("EvaluateWrongInput", testEvaluateWrongInput)
+	t.Run("EvaluateAgreement", testEvaluateAgreement)
+	t.Run("EvaluateAgreementNotExists", testEvaluateAgreementNotExists)
+}
+
+func evaluationMetrics() map[string][]model.MetricValue {
+	t0 := time.Now().Add(-time.Minute)
+	return map[string][]model.MetricValue{
+		"test_value": {
+			{Key: "test_value", Value: 5, DateTime: t0},
+			{Key: "test_value", Value: 15, DateTime: t0.Add(10 * time.Second)},
+			{Key: "test_value", Value: 8, DateTime: t0.Add(20 * time.Second)},
+		},
+	}
+}
+
+func checkEvaluation(t *testing.T, res *httptest.ResponseRecorder, violations int) {
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var result amodel.Result
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatalf("Error unmarshalling result: %v", err)
+	}
+	if actual := len(result.Violated["TestGuarantee"].Violations); actual != violations {
+		t.Errorf("Expected %d violations. Actual: %d (%v)", violations, actual, result)
+	}
+}
+
+func testEvaluate(t *testing.T) {
+	ae := createAgreement("ae02", p1, c2, "Posted agreement", nil)
+	ae.Details.Guarantees[0].Constraint = "test_value > 6"
+	body, _ := json.Marshal(model.Evaluation{Agreement: &ae, Metrics: evaluationMetrics()})
+	req, _ := http.NewRequest("POST", "/evaluate", bytes.NewBuffer(body))
+	res := request(req)
+	checkEvaluation(t, res, 1)
+
+	if _, err := repo.GetAgreement(ae.Id); err != model.ErrNotFound {
+		t.Errorf("Posted agreement should not be stored. Error: %v", err)
+	}
+	if vs, _ := repo.GetViolations(model.ViolationQuery{AgreementId: ae.Id}); len(vs) != 0 {
+		t.Errorf("Violations should not be stored. Found: %v", vs)
+	}
+}
+
+func testEvaluateByID(t *testing.T) {
+	body, _ := json.Marshal(model.Evaluation{AgreementID: "ae01", Metrics: evaluationMetrics()})
+	req, _ := http.NewRequest("POST", "/evaluate", bytes.NewBuffer(body))
+	res := request(req)
+	checkEvaluation(t, res, 2)
+
+	body, _ = json.Marshal(model.Evaluation{AgreementID: "doesnotexist", Metrics: evaluationMetrics()})
+	req, _ = http.NewRequest("POST", "/evaluate", bytes.NewBuffer(body))
+	res = request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testEvaluateWrongInput(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/evaluate", strings.NewReader("{"))
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+
+	ae := createAgreement("ae02", p1, c2, "Posted agreement", nil)
+	body, _ := json.Marshal(model.Evaluation{Agreement: &ae, AgreementID: "ae01"})
+	req, _ = http.NewRequest("POST", "/evaluate", bytes.NewBuffer(body))
+	res = request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+
+	ae.Details.Guarantees[0].Constraint = ""
+	body, _ = json.Marshal(model.Evaluation{Agreement: &ae})
+	req, _ = http.NewRequest("POST", "/evaluate", bytes.NewBuffer(body))
+	res = request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+
+	/* not a number nor a histogram, or histograms with unsorted buckets or decreasing counts */
+	for _, value := range []string{`"5"`, `true`, `null`,
+		`{"buckets":[{"le":200,"count":5},{"le":100,"count":3}]}`,
+		`{"buckets":[{"le":100,"count":5},{"le":200,"count":3}]}`} {
+		body := `{"agreement_id":"ae01","metrics":{"test_value":[` +
+			`{"key":"test_value","value":` + value + `,"datetime":"2018-01-16T00:00:00Z"}]}}`
+		for _, path := range []string{"/evaluate", "/agreements/ae01/evaluate"} {
+			req, _ = http.NewRequest("POST", path, strings.NewReader(body))
+			res = request(req)
+			checkError(t, res, http.StatusBadRequest, res.Code)
+		}
+	}
+}
+
+func testEvaluateAgreement(t *testing.T) {
+	body, _ := json.Marshal(model.Evaluation{Metrics: evaluationMetrics()})
+	req, _ := http.NewRequest("POST", "/agreements/ae01/evaluate", bytes.NewBuffer(body))
+	res := request(req)
+	checkEvaluation(t, res, 2)
+
+	if vs, _ := repo.GetViolations(model.ViolationQuery{AgreementId: "ae01"}); len(vs) != 0 {
+		t.Errorf("Violations should not be stored. Found: %v", vs)
+	}
+}
+
+func testEvaluateAgreementNotExists(t *testing.T) {
+	body, _ := json.Marshal(model.Evaluation{Metrics: evaluationMetrics()})
+	req, _ := http.NewRequest("POST", "/agreements/doesnotexist/evaluate", bytes.NewBuffer(body))
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func TestReassess(t *testing.T) {
+	ar := createAgreement("ar01", p1, c2, "Agreement to reassess", nil)
+	if _, err := repo.CreateAgreement(&ar); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+
+	t.Run("ReassessWithoutAdapter", testReassessWithoutAdapter)
+
+	retriever := genericadapter.MemoryRetriever(evaluationMetrics())
+	ma := genericadapter.New(retriever.Retrieve(), genericadapter.Aggregate)
+	noadapter := a
+	a, _ = NewApp(viper.New(), repo, model.NewDefaultValidator(false, true),
+		assessment.NewScheduler(repo, ma, nil, assessment.Config{}))
+	defer func() { a = noadapter }()
+	t.Run("Reassess", testReassess)
+	t.Run("ReassessRunning", testReassessRunning)
+	t.Run("ReassessWithWrongFilters", testReassessWithWrongFilters)
+	t.Run("ReassessTooManySteps", testReassessTooManySteps)
+	t.Run("ReassessNotExists", testReassessNotExists)
+	t.Run("GetReassessmentNotExists", testGetReassessmentNotExists)
+}
+
+func reassessURL(id string, from, to time.Time) string {
+	return fmt.Sprintf("/agreements/%s/reassess?from=%s&to=%s",
+		id, from.Format(time.RFC3339), to.Format(time.RFC3339))
+}
+
+// startReassessment starts a re-assessment, checking that it is accepted
+func startReassessment(t *testing.T, url string) model.Reassessment {
+	req, _ := http.NewRequest("POST", url, nil)
+	res := request(req)
+	checkStatus(t, http.StatusAccepted, res.Code)
+
+	var job model.Reassessment
+	_ = json.NewDecoder(res.Body).Decode(&job)
+	if location := res.Header().Get("Location"); location != "/reassessments/"+job.Id {
+		t.Errorf("Unexpected location of re-assessment %s: %s", job.Id, location)
+	}
+	return job
+}
+
+// waitReassessment polls a re-assessment until it finishes
+func waitReassessment(t *testing.T, id string) model.Reassessment {
+	var job model.Reassessment
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", "/reassessments/"+id, nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+		_ = json.NewDecoder(res.Body).Decode(&job)
+		if job.Status != model.ReassessmentRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Re-assessment %s did not finish: %v", id, job)
+	return job
+}
+
+func testReassessWithoutAdapter(t *testing.T) {
+	url := reassessURL("ar01", time.Now().Add(-time.Hour), time.Now())
+	req, _ := http.NewRequest("POST", url, nil)
+	res := request(req)
+	checkError(t, res, http.StatusServiceUnavailable, res.Code)
+}
+
+func testReassess(t *testing.T) {
+	url := reassessURL("ar01", time.Now().Add(-2*time.Minute), time.Now())
+	job := startReassessment(t, url)
+	if job.AgreementId != "ar01" || job.Status != model.ReassessmentRunning {
+		t.Errorf("Unexpected started re-assessment: %v", job)
+	}
+
+	job = waitReassessment(t, job.Id)
+	stored, _ := repo.GetViolations(model.ViolationQuery{AgreementId: "ar01"})
+	if job.Status != model.ReassessmentDone || job.End == nil {
+		t.Errorf("Unexpected finished re-assessment: %v", job)
+	}
+	if len(job.Violations) != 2 || len(stored) != 2 {
+		t.Fatalf("Expected 2 violations. Returned: %v. Stored: %v", job.Violations, stored)
+	}
+	for _, v := range stored {
+		if !v.Backfilled {
+			t.Errorf("Violation not marked as backfilled: %v", v)
+		}
+	}
+	if stored, _ := repo.GetAgreement("ar01"); !stored.Assessment.LastExecution.IsZero() {
+		t.Errorf("Agreement assessment should not be modified: %v", stored.Assessment)
+	}
+
+	/* the stored violations are not raised again */
+	job = waitReassessment(t, startReassessment(t, url).Id)
+	stored, _ = repo.GetViolations(model.ViolationQuery{AgreementId: "ar01"})
+	if len(job.Violations) != 0 || len(stored) != 2 {
+		t.Errorf("Expected no new violations. Returned: %v. Stored: %v", job.Violations, stored)
+	}
+}
+
+func testReassessRunning(t *testing.T) {
+	release := make(chan bool)
+	blocking := func(agreement model.Agreement, items []monitor.RetrievalItem) map[model.Variable][]model.MetricValue {
+		<-release
+		return map[model.Variable][]model.MetricValue{}
+	}
+	scheduler := a.scheduler
+	a.scheduler = assessment.NewScheduler(repo, genericadapter.New(blocking, genericadapter.Identity), nil, assessment.Config{})
+	defer func() { a.scheduler = scheduler }()
+
+	url := reassessURL("ar01", time.Now().Add(-2*time.Minute), time.Now())
+	job := startReassessment(t, url)
+
+	/* the agreement is being re-assessed */
+	req, _ := http.NewRequest("POST", url, nil)
+	res := request(req)
+	checkError(t, res, http.StatusConflict, res.Code)
+
+	close(release)
+	if job = waitReassessment(t, job.Id); job.Status != model.ReassessmentDone {
+		t.Errorf("Unexpected finished re-assessment: %v", job)
+	}
+}
+
+func testReassessWithWrongFilters(t *testing.T) {
+	for _, url := range []string{
+		"/agreements/ar01/reassess",
+		"/agreements/ar01/reassess?from=yesterday",
+		reassessURL("ar01", time.Now().Add(-time.Hour), time.Now().Add(-2*time.Hour)),
+		reassessURL("ar01", time.Now().Add(-31*24*time.Hour), time.Now()),
+	} {
+		req, _ := http.NewRequest("POST", url, nil)
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	}
+}
+
+func testReassessTooManySteps(t *testing.T) {
+	ar := createAgreement("ar02", p1, c2, "Agreement to reassess every second", nil)
+	ar.Details.Schedule = "PT1S"
+	if _, err := repo.CreateAgreement(&ar); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+	url := reassessURL(ar.Id, time.Now().Add(-24*time.Hour), time.Now())
+	req, _ := http.NewRequest("POST", url, nil)
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testReassessNotExists(t *testing.T) {
+	url := reassessURL("doesnotexist", time.Now().Add(-time.Hour), time.Now())
+	req, _ := http.NewRequest("POST", url, nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetReassessmentNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/reassessments/doesnotexist", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
 /********************************************************************
 *****************TEMPLATES******************************************
 ********************************************************************/
@@ -704,6 +1283,96 @@ func testCreateAgreementFromTemplateMissingFields(t *testing.T) {
 	checkStatus(t, http.StatusBadRequest, res.Code)
 }
 
+/********************************************************************
+*****************MAINTENANCE WINDOWS*********************************
+********************************************************************/
+
+func TestMaintenanceWindows(t *testing.T) {
+	t.Run("CreateMaintenanceWindow", testCreateMaintenanceWindow)
+	t.Run("CreateMaintenanceWindowWithWrongInput", testCreateMaintenanceWindowWithWrongInput)
+	t.Run("GetMaintenanceWindows", testGetMaintenanceWindows)
+	t.Run("GetMaintenanceWindowNotExists", testGetMaintenanceWindowNotExists)
+	t.Run("UpdateMaintenanceWindow", testUpdateMaintenanceWindow)
+	t.Run("DeleteMaintenanceWindow", testDeleteMaintenanceWindow)
+}
+
+func testCreateMaintenanceWindow(t *testing.T) {
+	start := time.Date(2019, 1, 7, 2, 0, 0, 0, time.UTC)
+	posted := model.MaintenanceWindow{
+		Id:         "mw01",
+		ProviderId: "p01",
+		Start:      start,
+		End:        start.Add(time.Hour),
+		Recurrence: "P1W",
+	}
+	body, _ := json.Marshal(posted)
+	req, _ := http.NewRequest("POST", "/maintenance-windows", bytes.NewBuffer(body))
+	res := request(req)
+	checkStatus(t, http.StatusCreated, res.Code)
+
+	var created model.MaintenanceWindow
+	_ = json.NewDecoder(res.Body).Decode(&created)
+	if created.Id != posted.Id || !created.Start.Equal(posted.Start) || created.Recurrence != posted.Recurrence {
+		t.Errorf("Expected: %v. Actual: %v", posted, created)
+	}
+}
+
+func testCreateMaintenanceWindowWithWrongInput(t *testing.T) {
+	body := `{"id": "mw02", "agreement_id": "a01", "start": "2019-01-07T02:00:00Z", "end": "2019-01-07T01:00:00Z"}`
+	req, _ := http.NewRequest("POST", "/maintenance-windows", strings.NewReader(body))
+	res := request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testGetMaintenanceWindows(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/maintenance-windows?provider=p01", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var windows model.MaintenanceWindows
+	_ = json.NewDecoder(res.Body).Decode(&windows)
+	if len(windows) != 1 || windows[0].Id != "mw01" {
+		t.Errorf("Expected only window mw01. Received: %v", windows)
+	}
+
+	req, _ = http.NewRequest("GET", "/maintenance-windows/mw01", nil)
+	res = request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+}
+
+func testGetMaintenanceWindowNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/maintenance-windows/doesnotexist", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testUpdateMaintenanceWindow(t *testing.T) {
+	body := `{"provider_id": "p01", "start": "2019-01-07T02:00:00Z", "end": "2019-01-07T04:00:00Z", "action": "tag"}`
+	req, _ := http.NewRequest("PUT", "/maintenance-windows/mw01", strings.NewReader(body))
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var updated model.MaintenanceWindow
+	_ = json.NewDecoder(res.Body).Decode(&updated)
+	if updated.Id != "mw01" || updated.Action != model.MaintenanceTag || updated.Recurrence != "" {
+		t.Errorf("Unexpected updated window: %v", updated)
+	}
+
+	req, _ = http.NewRequest("PUT", "/maintenance-windows/doesnotexist", strings.NewReader(body))
+	res = request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testDeleteMaintenanceWindow(t *testing.T) {
+	req, _ := http.NewRequest("DELETE", "/maintenance-windows/mw01", nil)
+	res := request(req)
+	checkStatus(t, http.StatusNoContent, res.Code)
+
+	req, _ = http.NewRequest("DELETE", "/maintenance-windows/mw01", nil)
+	res = request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
 func request(req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	a.Router.ServeHTTP(rr, req)
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// CalendarPeriod is the type of supported calendar periods
+type CalendarPeriod string
+
+const (
+	// DAY is the calendar period that starts at midnight
+	DAY CalendarPeriod = "day"
+	// WEEK is the calendar period that starts on Monday at midnight
+	WEEK CalendarPeriod = "week"
+	// MONTH is the calendar period that starts on the first day of the month at midnight
+	MONTH CalendarPeriod = "month"
+	// QUARTER is the calendar period that starts on the first day of January, April,
+	// July and October at midnight
+	QUARTER CalendarPeriod = "quarter"
+)
+
+// CalendarPeriods is the list of supported calendar periods
+var CalendarPeriods = [...]CalendarPeriod{DAY, WEEK, MONTH, QUARTER}
+
+// Calendar sets a period aligned to the calendar in a time zone, instead of a
+// period relative to the time of the evaluation.
+// swagger:model
+type Calendar struct {
+	Period CalendarPeriod `json:"period"`
+	// TimeZone is the IANA name of the time zone of the period boundaries
+	// (e.g. Europe/Madrid); default is UTC
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// IsValid returns if p is one of CalendarPeriods.
+func (p CalendarPeriod) IsValid() bool {
+	for _, valid := range CalendarPeriods {
+		if p == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if the period or the time zone of the calendar are not valid.
+func (c *Calendar) Check() error {
+	if !c.Period.IsValid() {
+		return fmt.Errorf("Calendar period '%s' is not valid", c.Period)
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		return fmt.Errorf("Calendar time zone '%s' is not valid", c.TimeZone)
+	}
+	return nil
+}
+
+// Location returns the time zone of the calendar. An invalid time zone is taken
+// as UTC.
+func (c *Calendar) Location() *time.Location {
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Start returns the start of the calendar period that contains t. An invalid
+// period returns t.
+func (c *Calendar) Start(t time.Time) time.Time {
+	loc := c.Location()
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch c.Period {
+	case DAY:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case WEEK:
+		monday := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-monday, 0, 0, 0, 0, loc)
+	case MONTH:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case QUARTER:
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, loc)
+	}
+	return t
+}
+
+// Next returns the start of the calendar period that follows the one that
+// contains t. An invalid period returns t.
+func (c *Calendar) Next(t time.Time) time.Time {
+	start := c.Start(t)
+	y, m, d := start.Date()
+	switch c.Period {
+	case DAY:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, start.Location())
+	case WEEK:
+		return time.Date(y, m, d+7, 0, 0, 0, 0, start.Location())
+	case MONTH:
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, start.Location())
+	case QUARTER:
+		return time.Date(y, m+3, 1, 0, 0, 0, 0, start.Location())
+	}
+	return t
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarCheck(t *testing.T) {
+	for _, c := range []Calendar{{Period: DAY}, {Period: QUARTER, TimeZone: "Europe/Madrid"}} {
+		if err := c.Check(); err != nil {
+			t.Errorf("Unexpected error checking calendar %v: %v", c, err)
+		}
+	}
+	for _, c := range []Calendar{{Period: "year"}, {Period: MONTH, TimeZone: "Europe/Nowhere"}} {
+		if err := c.Check(); err == nil {
+			t.Errorf("Expected error checking calendar %v", c)
+		}
+	}
+}
+
+func TestCalendarStartNext(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skipf("Time zone database not available: %v", err)
+	}
+	/* 2019-03-31 is the day of the change to summer time in Madrid */
+	now := time.Date(2019, 3, 31, 22, 30, 0, 0, time.UTC) // 2019-04-01 00:30 in Madrid
+
+	expected := map[CalendarPeriod][2]time.Time{
+		DAY:     {time.Date(2019, 4, 1, 0, 0, 0, 0, madrid), time.Date(2019, 4, 2, 0, 0, 0, 0, madrid)},
+		WEEK:    {time.Date(2019, 4, 1, 0, 0, 0, 0, madrid), time.Date(2019, 4, 8, 0, 0, 0, 0, madrid)},
+		MONTH:   {time.Date(2019, 4, 1, 0, 0, 0, 0, madrid), time.Date(2019, 5, 1, 0, 0, 0, 0, madrid)},
+		QUARTER: {time.Date(2019, 4, 1, 0, 0, 0, 0, madrid), time.Date(2019, 7, 1, 0, 0, 0, 0, madrid)},
+	}
+	for period, bounds := range expected {
+		c := Calendar{Period: period, TimeZone: "Europe/Madrid"}
+		if start := c.Start(now); !start.Equal(bounds[0]) {
+			t.Errorf("Unexpected start of %s. Expected: %v. Actual: %v", period, bounds[0], start)
+		}
+		if next := c.Next(now); !next.Equal(bounds[1]) {
+			t.Errorf("Unexpected next of %s. Expected: %v. Actual: %v", period, bounds[1], next)
+		}
+	}
+
+	/* in UTC, the same time is still in March */
+	c := Calendar{Period: MONTH}
+	if start := c.Start(now); !start.Equal(time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected start of month in UTC: %v", start)
+	}
+	/* the day of the change to summer time lasts 23 hours */
+	c = Calendar{Period: DAY, TimeZone: "Europe/Madrid"}
+	day := time.Date(2019, 3, 31, 12, 0, 0, 0, madrid)
+	if length := c.Next(day).Sub(c.Start(day)); length != 23*time.Hour {
+		t.Errorf("Unexpected length of day: %v", length)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"time"
+)
+
+// Compliance contains the attainment of a guarantee term in the current
+// and the previous compliance periods.
+//
+// swagger:model
+type Compliance struct {
+	Current  *CompliancePeriod `json:"current,omitempty"`
+	Previous *CompliancePeriod `json:"previous,omitempty"`
+	// LastPoint is the time of the last accounted point
+	LastPoint time.Time `json:"last_point"`
+}
+
+// CompliancePeriod contains the evaluated points of a guarantee term in a
+// compliance period, and the figures computed from them.
+//
+// Each point accounts for the time elapsed since the previous point (or since
+// the start of the period).
+//
+// swagger:model
+type CompliancePeriod struct {
+	Start time.Time `json:"start"`
+	// End is nil if the period is unbounded
+	End *time.Time `json:"end,omitempty"`
+	// Objective is the target of the guarantee term, if any
+	Objective    float64 `json:"objective,omitempty"`
+	Points       int     `json:"points"`
+	FailedPoints int     `json:"failed_points"`
+	// Time and FailedTime are in seconds
+	Time       float64 `json:"time"`
+	FailedTime float64 `json:"failed_time"`
+	// Attainment is the fraction of points that fulfilled the constraint
+	Attainment float64 `json:"attainment"`
+	// TimeAttainment is the fraction of time the constraint was fulfilled
+	TimeAttainment float64 `json:"time_attainment"`
+	// ErrorBudget is the fraction of the failed points allowed by the objective
+	// that remains; it is negative if the budget is exhausted. It is nil
+	// if there is no objective or the objective is 1.
+	ErrorBudget *float64 `json:"error_budget,omitempty"`
+}
+
+// Copy returns a deep copy of c. A nil Compliance returns an empty one.
+func (c *Compliance) Copy() *Compliance {
+	result := &Compliance{}
+	if c == nil {
+		return result
+	}
+	result.LastPoint = c.LastPoint
+	if c.Current != nil {
+		period := c.Current.copy()
+		result.Current = &period
+	}
+	if c.Previous != nil {
+		period := c.Previous.copy()
+		result.Previous = &period
+	}
+	return result
+}
+
+func (p *CompliancePeriod) copy() CompliancePeriod {
+	result := *p
+	if p.End != nil {
+		end := *p.End
+		result.End = &end
+	}
+	if p.ErrorBudget != nil {
+		budget := *p.ErrorBudget
+		result.ErrorBudget = &budget
+	}
+	return result
+}
+
+// AddPoint accounts a point evaluated at t, that fulfilled the constraint of the
+// guarantee term if failed is false. Points not after the last accounted point
+// are ignored.
+//
+// A new compliance period is started if t is after the end of the current one;
+// the current period becomes the previous one. The periods without points (when
+// no point is accounted for longer than a period) are skipped, so the previous
+// period is the last one with points, which may not be the one right before the
+// current one. o may be nil.
+func (c *Compliance) AddPoint(o *Objective, t time.Time, failed bool) {
+	if !c.LastPoint.IsZero() && !t.After(c.LastPoint) {
+		return
+	}
+	if c.Current == nil {
+		c.Current = newCompliancePeriod(o, t)
+	}
+	for c.Current.End != nil && !t.Before(*c.Current.End) {
+		if c.Current.Points > 0 {
+			c.Previous = c.Current
+		}
+		c.Current = newCompliancePeriod(o, *c.Current.End)
+	}
+
+	p := c.Current
+	elapsed := 0.0
+	if !c.LastPoint.IsZero() {
+		from := c.LastPoint
+		if from.Before(p.Start) {
+			from = p.Start
+		}
+		elapsed = t.Sub(from).Seconds()
+	}
+	p.Points++
+	p.Time += elapsed
+	if failed {
+		p.FailedPoints++
+		p.FailedTime += elapsed
+	}
+	if o != nil {
+		p.Objective = o.Target
+	}
+	p.update()
+	c.LastPoint = t
+}
+
+// newCompliancePeriod returns the compliance period that starts at start or, if the
+// objective sets a calendar, the calendar period that contains start.
+func newCompliancePeriod(o *Objective, start time.Time) *CompliancePeriod {
+	if o != nil && o.Calendar != nil {
+		start = o.Calendar.Start(start)
+		p := &CompliancePeriod{Start: start}
+		if end := o.Calendar.Next(start); end.After(start) {
+			p.End = &end
+		}
+		return p
+	}
+	p := &CompliancePeriod{Start: start}
+	if o == nil || o.Period == "" {
+		return p
+	}
+	if end, err := o.Period.Next(start); err == nil && end.After(start) {
+		p.End = &end
+	}
+	return p
+}
+
+// update computes the attainment and the error budget from the accounted points
+func (p *CompliancePeriod) update() {
+	p.Attainment = 1
+	if p.Points > 0 {
+		p.Attainment = 1 - float64(p.FailedPoints)/float64(p.Points)
+	}
+	p.TimeAttainment = 1
+	if p.Time > 0 {
+		p.TimeAttainment = 1 - p.FailedTime/p.Time
+	}
+	p.ErrorBudget = nil
+	if p.Objective > 0 && p.Objective < 1 {
+		budget := 1 - (1-p.Attainment)/(1-p.Objective)
+		p.ErrorBudget = &budget
+	}
+}
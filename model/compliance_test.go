@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComplianceAddPoint(t *testing.T) {
+	t0 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := &Objective{Target: 0.9, Period: "PT1H"}
+
+	c := &Compliance{}
+	for i, failed := range []bool{false, false, true, false} {
+		c.AddPoint(o, t0.Add(time.Duration(i)*time.Minute), failed)
+	}
+	/* repeated points are not accounted */
+	c.AddPoint(o, t0.Add(3*time.Minute), true)
+
+	p := c.Current
+	if p.Points != 4 || p.FailedPoints != 1 {
+		t.Errorf("Unexpected points. Expected: 4/1. Actual: %d/%d", p.Points, p.FailedPoints)
+	}
+	if p.Time != 180 || p.FailedTime != 60 {
+		t.Errorf("Unexpected time. Expected: 180/60. Actual: %v/%v", p.Time, p.FailedTime)
+	}
+	checkFloat(t, "Attainment", 0.75, p.Attainment)
+	checkFloat(t, "TimeAttainment", 2.0/3, p.TimeAttainment)
+	if p.ErrorBudget == nil {
+		t.Fatalf("Expected error budget")
+	}
+	checkFloat(t, "ErrorBudget", -1.5, *p.ErrorBudget)
+	if p.End == nil || !p.End.Equal(t0.Add(time.Hour)) {
+		t.Errorf("Unexpected period end: %v", p.End)
+	}
+
+	/* new period */
+	c.AddPoint(o, t0.Add(61*time.Minute), false)
+	if c.Previous == nil || c.Previous.Points != 4 {
+		t.Errorf("Unexpected previous period: %v", c.Previous)
+	}
+	if p = c.Current; !p.Start.Equal(t0.Add(time.Hour)) || p.Points != 1 || p.Time != 60 {
+		t.Errorf("Unexpected current period: %v", p)
+	}
+	checkFloat(t, "ErrorBudget", 1, *p.ErrorBudget)
+
+	/* gap longer than two periods: the previous period is the last one with points */
+	c.AddPoint(o, t0.Add(200*time.Minute), true)
+	if p = c.Previous; p == nil || !p.Start.Equal(t0.Add(time.Hour)) || p.Points != 1 || p.Attainment != 1 {
+		t.Errorf("Unexpected previous period: %v", p)
+	}
+	if p = c.Current; !p.Start.Equal(t0.Add(3*time.Hour)) || p.Points != 1 || p.Time != 20*60 {
+		t.Errorf("Unexpected current period: %v", p)
+	}
+
+	/* copies do not share periods */
+	copied := c.Copy()
+	copied.AddPoint(o, t0.Add(62*time.Minute), true)
+	if c.Current.Points != 1 {
+		t.Errorf("Copy modified original compliance: %v", c.Current)
+	}
+
+	/* no objective: unbounded period, no error budget */
+	c = nil
+	c = c.Copy()
+	c.AddPoint(nil, t0, true)
+	if c.Current.End != nil || c.Current.ErrorBudget != nil || c.Current.Attainment != 0 {
+		t.Errorf("Unexpected period without objective: %v", c.Current)
+	}
+}
+
+func TestComplianceAddPointWithCalendar(t *testing.T) {
+	o := &Objective{Target: 0.999, Calendar: &Calendar{Period: MONTH}}
+	t0 := time.Date(2018, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	c := &Compliance{}
+	c.AddPoint(o, t0, false)
+	p := c.Current
+	if !p.Start.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)) ||
+		p.End == nil || !p.End.Equal(time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected calendar period: %v - %v", p.Start, p.End)
+	}
+
+	/* February has no points: the previous period is January */
+	c.AddPoint(o, time.Date(2018, 3, 2, 0, 0, 0, 0, time.UTC), true)
+	if c.Previous == nil || !c.Previous.Start.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected previous period: %v", c.Previous)
+	}
+	if p = c.Current; !p.Start.Equal(time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)) || p.Time != 86400 {
+		t.Errorf("Unexpected current period: %v", p)
+	}
+}
+
+func checkFloat(t *testing.T, name string, expected, actual float64) {
+	if math.Abs(expected-actual) > 1e-9 {
+		t.Errorf("Unexpected %s. Expected: %v. Actual: %v", name, expected, actual)
+	}
+}
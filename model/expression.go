@@ -0,0 +1,229 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/Knetic/govaluate"
+)
+
+// ExpressionFunctionNames is the list of functions that can be used in the
+// expressions of a guarantee term (Constraint and Warning): abs(x); min(x, y, ...)
+// and max(x, y, ...); between(x, low, high), true if low <= x <= high; hour() and
+// weekday(), the hour (0-23) and day of the week (0 is Sunday) of the time of the
+// evaluated values; and isnull(x), true if x has no value.
+var ExpressionFunctionNames = [...]string{"abs", "min", "max", "between", "hour", "weekday", "isnull"}
+
+// Expression is a parsed expression of a guarantee term, that may use the
+// functions in ExpressionFunctionNames.
+//
+// An Expression must not be evaluated concurrently.
+type Expression struct {
+	*govaluate.EvaluableExpression
+	functions map[string]govaluate.ExpressionFunction
+	// sampleTime is the time of the values being evaluated
+	sampleTime *time.Time
+}
+
+// NewExpression parses a guarantee term expression.
+func NewExpression(expression string) (*Expression, error) {
+	sampleTime := &time.Time{}
+	functions := map[string]govaluate.ExpressionFunction{
+		"abs":     abs,
+		"min":     minimum,
+		"max":     maximum,
+		"between": between,
+		"isnull":  isnull,
+		"hour": func(args ...interface{}) (interface{}, error) {
+			if err := checkArgs("hour", args, 0); err != nil {
+				return nil, err
+			}
+			return float64(sampleTime.Hour()), nil
+		},
+		"weekday": func(args ...interface{}) (interface{}, error) {
+			if err := checkArgs("weekday", args, 0); err != nil {
+				return nil, err
+			}
+			return float64(sampleTime.Weekday()), nil
+		},
+	}
+	e, err := govaluate.NewEvaluableExpressionWithFunctions(expression, functions)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{EvaluableExpression: e, functions: functions, sampleTime: sampleTime}, nil
+}
+
+// EvaluateAt evaluates the expression with the values of the parameters, taken at t.
+func (e *Expression) EvaluateAt(parameters map[string]interface{}, t time.Time) (interface{}, error) {
+	*e.sampleTime = t
+	return e.Evaluate(parameters)
+}
+
+// FunctionName returns the name of the function of a FUNCTION token of the
+// expression, or "" if not found.
+func (e *Expression) FunctionName(token govaluate.ExpressionToken) string {
+	if token.Kind != govaluate.FUNCTION {
+		return ""
+	}
+	pointer := reflect.ValueOf(token.Value).Pointer()
+	for name, f := range e.functions {
+		if reflect.ValueOf(f).Pointer() == pointer {
+			return name
+		}
+	}
+	return ""
+}
+
+var (
+	// ignoredExpressionRegexp matches escaped variables and strings
+	ignoredExpressionRegexp = regexp.MustCompile(`\[[^\]]*\]|'[^']*'|"[^"]*"`)
+	functionCallRegexp      = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+)
+
+// CheckExpressionFunctions returns an error if an expression calls a function
+// that is not in ExpressionFunctionNames. The rest of the expression is not
+// checked, as it may contain template placeholders.
+func CheckExpressionFunctions(expression string) error {
+	stripped := ignoredExpressionRegexp.ReplaceAllString(expression, "")
+	for _, m := range functionCallRegexp.FindAllStringSubmatch(stripped, -1) {
+		name := m[1]
+		if name == "IN" {
+			continue
+		}
+		found := false
+		for _, valid := range ExpressionFunctionNames {
+			if name == valid {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Function '%s' in expression '%s' is not defined", name, expression)
+		}
+	}
+	return nil
+}
+
+// CheckBooleanExpression returns an error if an expression evaluates to a non
+// boolean value (e.g. "abs(a)"). The expression is evaluated with every variable
+// set to a number and, if the result is not boolean, set to true (for boolean
+// metrics). Expressions that cannot be parsed or evaluated with numbers (e.g. with
+// template placeholders or string values) are not checked.
+func CheckBooleanExpression(expression string) error {
+	if expression == "" {
+		return nil
+	}
+	e, err := NewExpression(expression)
+	if err != nil {
+		return nil
+	}
+	for i, value := range []interface{}{1.0, true} {
+		parameters := make(map[string]interface{})
+		for _, v := range e.Vars() {
+			parameters[v] = value
+		}
+		result, err := e.EvaluateAt(parameters, time.Now())
+		if err != nil && i == 0 {
+			return nil
+		}
+		if _, ok := result.(bool); err == nil && ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("Expression '%s' is not boolean", expression)
+}
+
+func checkArgs(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s() expects %d arguments; found %d", name, n, len(args))
+	}
+	return nil
+}
+
+func toFloats(name string, args []interface{}) ([]float64, error) {
+	result := make([]float64, 0, len(args))
+	for _, arg := range args {
+		f, ok := arg.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s() expects numeric arguments; found %v", name, arg)
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+func abs(args ...interface{}) (interface{}, error) {
+	if err := checkArgs("abs", args, 1); err != nil {
+		return nil, err
+	}
+	x, err := toFloats("abs", args)
+	if err != nil {
+		return nil, err
+	}
+	return math.Abs(x[0]), nil
+}
+
+func minimum(args ...interface{}) (interface{}, error) {
+	return reduce("min", args, math.Min)
+}
+
+func maximum(args ...interface{}) (interface{}, error) {
+	return reduce("max", args, math.Max)
+}
+
+func reduce(name string, args []interface{}, f func(float64, float64) float64) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s() expects at least one argument", name)
+	}
+	x, err := toFloats(name, args)
+	if err != nil {
+		return nil, err
+	}
+	result := x[0]
+	for _, v := range x[1:] {
+		result = f(result, v)
+	}
+	return result, nil
+}
+
+func between(args ...interface{}) (interface{}, error) {
+	if err := checkArgs("between", args, 3); err != nil {
+		return nil, err
+	}
+	x, err := toFloats("between", args)
+	if err != nil {
+		return nil, err
+	}
+	return x[1] <= x[0] && x[0] <= x[2], nil
+}
+
+func isnull(args ...interface{}) (interface{}, error) {
+	// govaluate calls the function without arguments if the argument is nil
+	if len(args) == 0 {
+		return true, nil
+	}
+	if err := checkArgs("isnull", args, 1); err != nil {
+		return nil, err
+	}
+	return args[0] == nil, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpressionFunctions(t *testing.T) {
+	// 2018-01-07 is a Sunday
+	sunday := time.Date(2018, 1, 7, 15, 30, 0, 0, time.UTC)
+	monday := sunday.Add(24 * time.Hour)
+	params := map[string]interface{}{"a": -3.0, "b": 5.0, "n": nil}
+
+	expected := map[string]bool{
+		"abs(a) == 3":                   true,
+		"min(a, b, 0) == a":             true,
+		"max(a, b) == b":                true,
+		"between(b, 0, 10)":             true,
+		"between(a, 0, 10)":             false,
+		"hour() == 15":                  true,
+		"weekday() == 0":                true,
+		"isnull(n) && !isnull(a)":       true,
+		"b < 2 || weekday() == 0":       true,
+		"between(abs(a), 2, max(a, 4))": true,
+	}
+	for s, value := range expected {
+		e, err := NewExpression(s)
+		if err != nil {
+			t.Errorf("Error parsing '%s': %v", s, err)
+			continue
+		}
+		result, err := e.EvaluateAt(params, sunday)
+		if err != nil || result != value {
+			t.Errorf("Unexpected result of '%s'. Expected: %v. Actual: %v (%v)", s, value, result, err)
+		}
+	}
+
+	e, _ := NewExpression("weekday() == 0")
+	if result, _ := e.EvaluateAt(params, monday); result != false {
+		t.Errorf("Unexpected result of weekday() on monday: %v", result)
+	}
+
+	for _, s := range []string{"abs(a, b) > 0", "between(a, 1) == true", "hour(a) > 0"} {
+		e, _ := NewExpression(s)
+		if _, err := e.EvaluateAt(params, sunday); err == nil {
+			t.Errorf("Expected error evaluating '%s'", s)
+		}
+	}
+
+	if _, err := NewExpression("sqrt(a) > 0"); err == nil {
+		t.Errorf("Expected error parsing undefined function")
+	}
+
+	e, _ = NewExpression("between(a, 0, 10) && abs(b) > 0")
+	tokens := e.Tokens()
+	if name := e.FunctionName(tokens[0]); name != "between" {
+		t.Errorf("Unexpected function name. Expected: between. Actual: %s", name)
+	}
+	if name := e.FunctionName(tokens[1]); name != "" {
+		t.Errorf("Unexpected function name of a non function token: %s", name)
+	}
+}
+
+func TestCheckExpressionFunctions(t *testing.T) {
+	valid := []string{
+		"",
+		"a < 10",
+		"abs(a) < 10 && (b > 0 || weekday() == 0)",
+		"[metric(1)] > 0",
+		"a < {{.M}}",
+		"a IN (1, 2)",
+	}
+	for _, s := range valid {
+		if err := CheckExpressionFunctions(s); err != nil {
+			t.Errorf("Unexpected error checking '%s': %v", s, err)
+		}
+	}
+	if err := CheckExpressionFunctions("sqrt(a) < 10"); err == nil {
+		t.Errorf("Expected error checking undefined function")
+	}
+}
+
+func TestCheckBooleanExpression(t *testing.T) {
+	valid := []string{
+		"",
+		"a < 10",
+		"abs(a) < 10 && weekday() != 0",
+		"between(a, 0, 10)",
+		"isnull(a)",
+		"enabled",
+		"enabled && a > 0",
+		"a < {{.M}}",
+	}
+	for _, s := range valid {
+		if err := CheckBooleanExpression(s); err != nil {
+			t.Errorf("Unexpected error checking '%s': %v", s, err)
+		}
+	}
+	for _, s := range []string{"abs(a)", "max(a, b) - 1", "hour()", "10"} {
+		if err := CheckBooleanExpression(s); err == nil {
+			t.Errorf("Expected error checking non boolean expression '%s'", s)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// Histogram is a metric value that summarizes a set of observations (e.g. the
+// latencies of the requests) in buckets, instead of the raw observations.
+// A MetricValue carries a histogram as a *Histogram.
+// swagger:model
+type Histogram struct {
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Bucket is a bucket of a Histogram. As in Prometheus, the counts are cumulative:
+// Count is the number of observations less than or equal to UpperBound.
+// swagger:model
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      float64 `json:"count"`
+}
+
+// Check returns an error if the buckets are not sorted by increasing upper bound,
+// or the counts are negative or decrease.
+func (h *Histogram) Check() error {
+	for i, b := range h.Buckets {
+		if b.Count < 0 {
+			return fmt.Errorf("Histogram bucket %v has a negative count", b.UpperBound)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := h.Buckets[i-1]
+		if b.UpperBound <= prev.UpperBound {
+			return fmt.Errorf("Histogram buckets are not sorted by upper bound")
+		}
+		if b.Count < prev.Count {
+			return fmt.Errorf("Histogram bucket %v has fewer observations than the previous bucket", b.UpperBound)
+		}
+	}
+	return nil
+}
+
+// Total returns the number of observations in the histogram, i.e., the count of
+// the last bucket.
+func (h *Histogram) Total() float64 {
+	if len(h.Buckets) == 0 {
+		return 0
+	}
+	return h.Buckets[len(h.Buckets)-1].Count
+}
+
+// CountAt returns the number of observations less than or equal to bound, as
+// known from the buckets: the count of the bucket with the greatest upper bound
+// not greater than bound, or 0 if there is none.
+func (h *Histogram) CountAt(bound float64) float64 {
+	result := 0.0
+	for _, b := range h.Buckets {
+		if b.UpperBound > bound {
+			break
+		}
+		result = b.Count
+	}
+	return result
+}
+
+// AsHistogram returns the histogram carried by the metric value, and false if the
+// value is not a histogram.
+func (v MetricValue) AsHistogram() (*Histogram, bool) {
+	h, ok := v.Value.(*Histogram)
+	return h, ok && h != nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so that a value with buckets
+// (i.e., {"buckets": [{"le": 100, "count": 3}, ...]}) is decoded as a *Histogram.
+func (v *MetricValue) UnmarshalJSON(data []byte) error {
+	type plain MetricValue
+	aux := struct {
+		*plain
+		Value json.RawMessage `json:"value"`
+	}{plain: (*plain)(v)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	v.Value = nil
+	raw := bytes.TrimSpace(aux.Value)
+	if len(raw) == 0 {
+		return nil
+	}
+	if raw[0] == '{' {
+		var h Histogram
+		if err := json.Unmarshal(raw, &h); err == nil && h.Buckets != nil {
+			v.Value = &h
+			return nil
+		}
+	}
+	return json.Unmarshal(raw, &v.Value)
+}
+
+// SetBSON implements bson.Setter, so that a stored histogram (e.g. in the last
+// values of an assessment) is decoded as a *Histogram instead of a bson.M.
+func (v *MetricValue) SetBSON(raw bson.Raw) error {
+	var aux struct {
+		Key      string
+		Value    bson.Raw
+		DateTime time.Time
+	}
+	if err := raw.Unmarshal(&aux); err != nil {
+		return err
+	}
+	v.Key = aux.Key
+	v.DateTime = aux.DateTime
+	v.Value = nil
+	if aux.Value.Kind == 0 {
+		return nil
+	}
+	if aux.Value.Kind == bsonDocument {
+		var h Histogram
+		if err := aux.Value.Unmarshal(&h); err == nil && h.Buckets != nil {
+			v.Value = &h
+			return nil
+		}
+	}
+	return aux.Value.Unmarshal(&v.Value)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+func TestHistogram(t *testing.T) {
+	h := Histogram{Buckets: []Bucket{{100, 50}, {200, 90}, {300, 100}}}
+	if err := h.Check(); err != nil {
+		t.Errorf("Unexpected error checking histogram %v: %v", h, err)
+	}
+	if total := h.Total(); total != 100 {
+		t.Errorf("Unexpected total. Expected: 100. Actual: %v", total)
+	}
+	for bound, expected := range map[float64]float64{50: 0, 100: 50, 250: 90, 1000: 100} {
+		if actual := h.CountAt(bound); actual != expected {
+			t.Errorf("Unexpected count at %v. Expected: %v. Actual: %v", bound, expected, actual)
+		}
+	}
+	if total := (&Histogram{}).Total(); total != 0 {
+		t.Errorf("Unexpected total of empty histogram: %v", total)
+	}
+
+	wrong := []Histogram{
+		{Buckets: []Bucket{{200, 50}, {100, 90}}},
+		{Buckets: []Bucket{{100, 50}, {200, 40}}},
+		{Buckets: []Bucket{{100, -1}}},
+	}
+	for _, h := range wrong {
+		if err := h.Check(); err == nil {
+			t.Errorf("Expected error checking histogram %v", h)
+		}
+	}
+}
+
+func TestUnmarshalMetricValue(t *testing.T) {
+	var values []MetricValue
+	data := `[
+		{"key": "latency", "value": {"buckets": [{"le": 100, "count": 3}, {"le": 200, "count": 5}]}, "datetime": "2019-03-01T00:00:00Z"},
+		{"key": "m", "value": 1.5, "datetime": "2019-03-01T00:00:00Z"},
+		{"key": "m", "value": {"other": 1}}
+	]`
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h, ok := values[0].AsHistogram()
+	if !ok || len(h.Buckets) != 2 || h.Buckets[1] != (Bucket{200, 5}) {
+		t.Errorf("Unexpected histogram value: %v", values[0])
+	}
+	if values[0].Key != "latency" || values[0].DateTime.IsZero() {
+		t.Errorf("Unexpected metric value: %v", values[0])
+	}
+	if values[1].Value != 1.5 {
+		t.Errorf("Unexpected numeric value: %v", values[1])
+	}
+	if _, ok := values[1].AsHistogram(); ok {
+		t.Errorf("Value %v must not be a histogram", values[1])
+	}
+	if _, ok := values[2].Value.(map[string]interface{}); !ok {
+		t.Errorf("Unexpected object value: %v", values[2])
+	}
+	if err := json.Unmarshal([]byte(`[{"key": "m", "value": [}]`), &values); err == nil {
+		t.Errorf("Expected error unmarshalling wrong value")
+	}
+}
+
+func TestMetricValueBSON(t *testing.T) {
+	t0 := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	ag := AssessmentGuarantee{LastValues: LastValues{
+		"latency": {Key: "latency", Value: &Histogram{Buckets: []Bucket{{100, 3}, {200, 5}}}, DateTime: t0},
+		"m":       {Key: "m", Value: 1.5, DateTime: t0},
+		"o":       {Key: "o", Value: bson.M{"other": 1}, DateTime: t0},
+		"empty":   {Key: "empty", DateTime: t0},
+	}}
+	data, err := bson.Marshal(ag)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var stored AssessmentGuarantee
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	latency := stored.LastValues["latency"]
+	h, ok := latency.AsHistogram()
+	if !ok || len(h.Buckets) != 2 || h.Buckets[1] != (Bucket{200, 5}) {
+		t.Errorf("Unexpected histogram value: %v", latency)
+	}
+	if latency.Key != "latency" || !latency.DateTime.Equal(t0) {
+		t.Errorf("Unexpected metric value: %v", latency)
+	}
+	if v := stored.LastValues["m"].Value; v != 1.5 {
+		t.Errorf("Unexpected numeric value: %v", v)
+	}
+	if _, ok := stored.LastValues["o"].Value.(bson.M); !ok {
+		t.Errorf("Unexpected document value: %v", stored.LastValues["o"])
+	}
+	if v := stored.LastValues["empty"].Value; v != nil {
+		t.Errorf("Unexpected empty value: %v", v)
+	}
+}
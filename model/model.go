@@ -22,9 +22,14 @@ by any repository.
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/globalsign/mgo/bson"
 )
 
 //
@@ -81,6 +86,25 @@ type TextType string
 // AggregationType is the type of supported variable aggregations
 type AggregationType string
 
+// MetricType is the type of supported kinds of metrics
+type MetricType string
+
+// InterpolationType is the type of supported strategies to align variable values in time
+type InterpolationType string
+
+// NoDataPolicy is the type of possible outcomes of a guarantee term without monitoring data
+type NoDataPolicy string
+
+// Status is the type of possible statuses of an assessed guarantee term or agreement
+type Status string
+
+// MaintenanceAction is the type of possible actions on the violations raised during
+// a maintenance window
+type MaintenanceAction string
+
+// ReassessmentStatus is the type of possible statuses of a re-assessment
+type ReassessmentStatus string
+
 const (
 	// STARTED is the state of an agreement that can be evaluated
 	STARTED State = "started"
@@ -105,8 +129,113 @@ const (
 	NONE AggregationType = "none"
 	// AVERAGE is used to calculate average of a variable
 	AVERAGE AggregationType = "average"
+	// MIN is used to calculate the minimum value of a variable
+	MIN AggregationType = "min"
+	// MAX is used to calculate the maximum value of a variable
+	MAX AggregationType = "max"
+	// SUM is used to calculate the sum of the values of a variable
+	SUM AggregationType = "sum"
+	// COUNT is used to calculate the number of values of a variable
+	COUNT AggregationType = "count"
+	// MEDIAN is used to calculate the median of a variable
+	MEDIAN AggregationType = "median"
+	// RATE is used to calculate the per-second increase of a counter variable
+	RATE AggregationType = "rate"
+	// INCREASE is used to calculate the increase of a counter variable
+	INCREASE AggregationType = "increase"
+)
+
+const (
+	// GAUGE is the type of a metric whose values may go up and down
+	GAUGE MetricType = "gauge"
+	// COUNTER is the type of a monotonically increasing metric, which goes back to
+	// zero on a reset
+	COUNTER MetricType = "counter"
+	// HISTOGRAM is the type of a metric whose values are histograms, each one with
+	// its own observations (see Histogram)
+	HISTOGRAM MetricType = "histogram"
+	// COUNTERHISTOGRAM is the type of a metric whose values are histograms whose
+	// buckets are counters, i.e., they count the observations since the metric
+	// started (as the histograms of Prometheus)
+	COUNTERHISTOGRAM MetricType = "counter_histogram"
+)
+
+const (
+	// CONSTANT is used to take the last known value of a variable that has no value
+	// at the time of a point
+	CONSTANT InterpolationType = "constant"
+	// LINEAR is used to interpolate linearly between the previous and next values of
+	// a variable that has no value at the time of a point
+	LINEAR InterpolationType = "linear"
+	// STRICT is used to discard the points where a variable has no value
+	STRICT InterpolationType = "strict"
+)
+
+const (
+	// NoDataIgnore is used to evaluate a guarantee term without data as if it were fulfilled
+	NoDataIgnore NoDataPolicy = "ignore"
+	// NoDataUnknown is used to mark a guarantee term without data as unknown
+	NoDataUnknown NoDataPolicy = "unknown"
+	// NoDataViolation is used to mark a guarantee term without data as unknown, and to raise
+	// a violation when the data stops
+	NoDataViolation NoDataPolicy = "violation"
 )
 
+const (
+	// FULFILLED is the status of a guarantee term whose last point fulfilled the constraint
+	FULFILLED Status = "fulfilled"
+	// WARNING is the status of a guarantee term whose last point failed the warning,
+	// or failed the constraint within the tolerance of the term
+	WARNING Status = "warning"
+	// VIOLATED is the status of a guarantee term whose last point raised a violation
+	VIOLATED Status = "violated"
+	// UNKNOWN is the status of a guarantee term without points, without fresh data,
+	// or that could not be evaluated
+	UNKNOWN Status = "unknown"
+)
+
+const (
+	// MaintenanceSkip is used to discard the violations raised during a maintenance window
+	MaintenanceSkip MaintenanceAction = "skip"
+	// MaintenanceTag is used to keep the violations raised during a maintenance window,
+	// tagged with the window, without raising penalties nor incidents, and without
+	// notifying them
+	MaintenanceTag MaintenanceAction = "tag"
+)
+
+const (
+	// ReassessmentRunning is the status of a re-assessment that has not finished
+	ReassessmentRunning ReassessmentStatus = "running"
+	// ReassessmentDone is the status of a re-assessment that finished
+	ReassessmentDone ReassessmentStatus = "done"
+	// ReassessmentFailed is the status of a re-assessment that stopped on an error
+	ReassessmentFailed ReassessmentStatus = "failed"
+)
+
+// DefaultInterpolation is the interpolation used if not set in the agreement nor in the variable
+var DefaultInterpolation = NewInterpolation(CONSTANT, 0.1)
+
+// AggregationTypes is the list of supported aggregation types, besides the
+// percentiles (see AggregationType.Percentile)
+var AggregationTypes = [...]AggregationType{NONE, AVERAGE, MIN, MAX, SUM, COUNT, MEDIAN, RATE, INCREASE}
+
+// MetricTypes is the list of supported metric types
+var MetricTypes = [...]MetricType{GAUGE, COUNTER, HISTOGRAM, COUNTERHISTOGRAM}
+
+// InterpolationTypes is the list of supported interpolation types
+var InterpolationTypes = [...]InterpolationType{CONSTANT, LINEAR, STRICT}
+
+// NoDataPolicies is the list of supported policies on guarantee terms without data
+var NoDataPolicies = [...]NoDataPolicy{NoDataIgnore, NoDataUnknown, NoDataViolation}
+
+// Statuses is the list of possible statuses of a guarantee term/agreement, from
+// the best to the worst
+var Statuses = [...]Status{FULFILLED, WARNING, UNKNOWN, VIOLATED}
+
+// MaintenanceActions is the list of supported actions on the violations raised during
+// a maintenance window
+var MaintenanceActions = [...]MaintenanceAction{MaintenanceSkip, MaintenanceTag}
+
 // States is the list of possible states of an agreement/template
 var States = [...]State{STOPPED, STARTED, TERMINATED}
 
@@ -172,6 +301,35 @@ type CreateAgreement struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
+// Evaluation is the resource used to evaluate an agreement against a set of metric
+// series, without persisting nor notifying the result. The agreement to evaluate is
+// Agreement or, if not set, the stored agreement identified by AgreementID.
+// Metrics contains a series of values for each metric name (metric[member] for the
+// values of a scope member). If Now is not set, the time of the newer value is used.
+// swagger:model
+type Evaluation struct {
+	Agreement   *Agreement               `json:"agreement,omitempty"`
+	AgreementID string                   `json:"agreement_id,omitempty"`
+	Now         *time.Time               `json:"now,omitempty"`
+	Metrics     map[string][]MetricValue `json:"metrics"`
+}
+
+// Reassessment is a job that re-assesses a stored agreement over the past interval
+// [From, To] in background. Violations are the violations raised by the job, set
+// when it finishes; Error is set if it failed.
+// swagger:model
+type Reassessment struct {
+	Id          string             `json:"id"`
+	AgreementId string             `json:"agreement_id"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Status      ReassessmentStatus `json:"status"`
+	Start       time.Time          `json:"start"`
+	End         *time.Time         `json:"end,omitempty"`
+	Violations  []Violation        `json:"violations"`
+	Error       string             `json:"error,omitempty"`
+}
+
 // Agreement is the entity that represents an agreement between a provider and a client.
 // The Text is ReadOnly in normal conditions, with the exception of a renegotiation.
 // The Assessment cannot be modified externally.
@@ -194,6 +352,12 @@ type Assessment struct {
 	LastExecution  time.Time `json:"last_execution"`
 	// Guarantees may be nil. Use Assessment.SetGuarantee to create if needed.
 	Guarantees map[string]AssessmentGuarantee `json:"guarantees,omitempty"`
+	// TimedOut is set if the last assessment of the agreement did not finish in time.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// Status is the worst status of the assessed guarantee terms
+	Status Status `json:"status,omitempty"`
+	// StatusChange is the time of the assessment that changed the status
+	StatusChange *time.Time `json:"status_change,omitempty"`
 }
 
 // AssessmentGuarantee contain the assessment information for a guarantee term
@@ -203,6 +367,44 @@ type AssessmentGuarantee struct {
 	FirstExecution time.Time  `json:"first_execution"`
 	LastExecution  time.Time  `json:"last_execution"`
 	LastValues     LastValues `json:"last_values,omitempty"`
+	// Failing is the current streak of failing points. It is nil if the last
+	// evaluated point fulfilled the constraint.
+	Failing *FailingStreak `json:"failing,omitempty"`
+	// Incident is the id of the open incident of the guarantee term, if any.
+	Incident string `json:"incident,omitempty"`
+	// Compliance is the attainment of the guarantee term in the current and
+	// previous compliance periods (see Guarantee.Objective)
+	Compliance *Compliance `json:"compliance,omitempty"`
+
+	// Error is the error of the last evaluation of the guarantee term, if it
+	// could not be evaluated. It is nil after a successful evaluation.
+	Error *EvaluationError `json:"error,omitempty"`
+	// NoDataSince is the time since the guarantee term has no fresh monitoring data,
+	// if its OnNoData policy is not ignore. It is nil if the term has fresh data.
+	NoDataSince *time.Time `json:"no_data_since,omitempty"`
+	// Status is the status of the guarantee term after its last evaluation
+	Status Status `json:"status,omitempty"`
+	// StatusChange is the time of the evaluation that changed the status
+	StatusChange *time.Time `json:"status_change,omitempty"`
+}
+
+// EvaluationError is an error that prevented a guarantee term from being evaluated
+//
+// swagger:model
+type EvaluationError struct {
+	Message  string    `json:"message"`
+	Datetime time.Time `json:"datetime"`
+}
+
+// FailingStreak contains the information of consecutive points that failed
+// the constraint of a guarantee term (see Guarantee.Tolerance)
+//
+// swagger:model
+type FailingStreak struct {
+	Points int       `json:"points"`
+	Since  time.Time `json:"since"`
+	// Violated is set when the streak has exceeded the tolerance of the term
+	Violated bool `json:"violated,omitempty"`
 }
 
 // LastValues contain last values of variables in guarantee terms
@@ -222,6 +424,14 @@ type Details struct {
 	Expiration *time.Time  `json:"expiration,omitempty"`
 	Variables  []Variable  `json:"variables,omitempty"`
 	Guarantees []Guarantee `json:"guarantees"`
+
+	// Interpolation is how the values of the variables are aligned in time to
+	// evaluate the guarantee terms, unless set in the variable
+	Interpolation *Interpolation `json:"interpolation,omitempty"`
+
+	// Schedule is how often the agreement is assessed; if not set, the agreement is
+	// assessed on the default period of the assessment.Scheduler
+	Schedule Schedule `json:"schedule,omitempty"`
 }
 
 // Variable gives additional information about a metric used in a Guarantee constraint
@@ -230,19 +440,165 @@ type Variable struct {
 	Name        string       `json:"name"`
 	Metric      string       `json:"metric"`
 	Aggregation *Aggregation `json:"aggregation,omitempty"`
+
+	// Interpolation overrides the interpolation set in the agreement details
+	Interpolation *Interpolation `json:"interpolation,omitempty"`
+
+	// Ratio defines the variable as the ratio of two metrics instead of a Metric
+	Ratio *Ratio `json:"ratio,omitempty"`
+
+	// Type is the type of the metric; GAUGE if not set
+	Type MetricType `json:"type,omitempty"`
+}
+
+// Ratio defines a variable as the ratio of two metrics, e.g. good events over
+// total events, each one with its own aggregation. The value of the variable
+// is Good / Total, multiplied by Scale (e.g. 100 for a percentage; 1 if not set).
+// swagger:model
+type Ratio struct {
+	Good  RatioMetric `json:"good"`
+	Total RatioMetric `json:"total"`
+	Scale float64     `json:"scale,omitempty"`
+}
+
+// RatioMetric is one of the metrics of a Ratio
+// swagger:model
+type RatioMetric struct {
+	Metric      string       `json:"metric"`
+	Aggregation *Aggregation `json:"aggregation,omitempty"`
+	Type        MetricType   `json:"type,omitempty"`
+}
+
+// IsRatio returns if the variable is defined as a ratio of two metrics
+func (v Variable) IsRatio() bool {
+	return v.Ratio != nil
+}
+
+// RatioVariables returns the variables of the good and total metrics of a ratio
+// variable, named as the variable with a ".good" and ".total" suffix. The
+// variables have the interpolation of v.
+func (v Variable) RatioVariables() (good Variable, total Variable) {
+	if v.Ratio == nil {
+		return
+	}
+	good = Variable{
+		Name:          v.Name + ".good",
+		Metric:        v.Ratio.Good.Metric,
+		Aggregation:   v.Ratio.Good.Aggregation,
+		Interpolation: v.Interpolation,
+		Type:          v.Ratio.Good.Type,
+	}
+	total = Variable{
+		Name:          v.Name + ".total",
+		Metric:        v.Ratio.Total.Metric,
+		Aggregation:   v.Ratio.Total.Aggregation,
+		Interpolation: v.Interpolation,
+		Type:          v.Ratio.Total.Type,
+	}
+	return
+}
+
+// IsCounter returns if the metric of the variable is a counter, or a histogram
+// of counters
+func (v Variable) IsCounter() bool {
+	return v.Type == COUNTER || v.Type == COUNTERHISTOGRAM
+}
+
+// IsHistogram returns if the values of the metric of the variable are histograms
+func (v Variable) IsHistogram() bool {
+	return v.Type.IsHistogram()
+}
+
+// IsHistogram returns if the values of a metric of type t are histograms
+func (t MetricType) IsHistogram() bool {
+	return t == HISTOGRAM || t == COUNTERHISTOGRAM
+}
+
+// GetScale returns the scale of the ratio, applying the default value
+func (r *Ratio) GetScale() float64 {
+	if r.Scale == 0 {
+		return 1
+	}
+	return r.Scale
 }
 
 // Aggregation gives aggregation information of a variable.
 // If defined and value is not NONE, the metric must be aggregated
 // in the specified window in seconds.
 // I.e. (average, 3600) means that the average over a period of one hour is calculated.
+// If Calendar is set instead of Window, the metric is aggregated since the start of
+// the calendar period that contains the time of the evaluation.
 // swagger:model
 type Aggregation struct {
 	Type   AggregationType `json:"type"`
 	Window int             `json:"window"`
+
+	// Calendar sets a calendar-aligned window (e.g. the current month)
+	Calendar *Calendar `json:"calendar,omitempty"`
+}
+
+// From returns the start of the window of the aggregation that ends at "to", and
+// false if the aggregation has no window (i.e., it is not windowed).
+func (a *Aggregation) From(to time.Time) (time.Time, bool) {
+	if a == nil {
+		return to, false
+	}
+	if a.Calendar != nil {
+		return a.Calendar.Start(to), true
+	}
+	if a.Window != 0 {
+		return to.Add(-time.Duration(a.Window) * time.Second), true
+	}
+	return to, false
+}
+
+// Interpolation sets how the values of a variable are aligned in time with the values
+// of the rest of variables of a guarantee term. A value of a variable is aligned
+// with a point if their times differ less than Delta seconds (a Delta of 0 only
+// aligns values with the same time); if the variable has no aligned value, Type
+// sets how its value at the time of the point is calculated.
+// Unset fields take the value of the enclosing interpolation (agreement or
+// DefaultInterpolation).
+// swagger:model
+type Interpolation struct {
+	Type  InterpolationType `json:"type,omitempty"`
+	Delta *float64          `json:"delta,omitempty"`
+}
+
+// NewInterpolation returns an Interpolation of type t and delta seconds
+func NewInterpolation(t InterpolationType, delta float64) Interpolation {
+	return Interpolation{Type: t, Delta: &delta}
+}
+
+// GetDelta returns the Delta of the interpolation, or 0 if not set
+func (i Interpolation) GetDelta() float64 {
+	if i.Delta == nil {
+		return 0
+	}
+	return *i.Delta
+}
+
+// Merge returns the interpolation i, with the unset fields taken from defaults.
+// i may be nil.
+func (i *Interpolation) Merge(defaults Interpolation) Interpolation {
+	if i == nil {
+		return defaults
+	}
+	result := *i
+	if result.Type == "" {
+		result.Type = defaults.Type
+	}
+	if result.Delta == nil {
+		result.Delta = defaults.Delta
+	}
+	return result
 }
 
 // Guarantee is the struct that represents an SLO
+//
+// OnNoData sets the outcome of an evaluation of the term without fresh monitoring data,
+// i.e., without values or, if Staleness is set, whose newest value is older than
+// Staleness seconds. Default is ignore.
 // swagger:model
 type Guarantee struct {
 	Name       string       `json:"name"`
@@ -251,12 +607,95 @@ type Guarantee struct {
 	Schedule   Schedule     `json:"schedule,omitempty"`
 	Warning    string       `json:"warning,omitempty"`
 	Penalties  []PenaltyDef `json:"penalties,omitempty"`
+	Tolerance  *Tolerance   `json:"tolerance,omitempty"`
+	Objective  *Objective   `json:"objective,omitempty"`
+	OnNoData   NoDataPolicy `json:"on_no_data,omitempty"`
+	Staleness  int          `json:"staleness,omitempty"`
 }
 
-// Scope is the resources a guarantee term applies on
-type Scope string
+// Objective sets the target compliance of a guarantee term, i.e. the fraction of
+// evaluated points that must fulfill the constraint over a compliance period.
+// swagger:model
+type Objective struct {
+	// Target is the fraction of compliant points, in (0, 1]; e.g. 0.999
+	Target float64 `json:"target"`
+	// Period is the length of the compliance period. If empty, the period starts
+	// on the first evaluation of the term and never ends.
+	Period Schedule `json:"period,omitempty"`
+	// Calendar sets calendar-aligned compliance periods (e.g. calendar months in a
+	// time zone) instead of Period
+	Calendar *Calendar `json:"calendar,omitempty"`
+}
+
+// Tolerance sets how many failing points of a guarantee term are tolerated
+// before raising violations. If both fields are set, both conditions must hold.
+// swagger:model
+type Tolerance struct {
+	// Points is the number of consecutive failing points needed to raise a violation
+	Points int `json:"points,omitempty"`
+	// Duration is the number of seconds the constraint must have been failing
+	// to raise a violation
+	Duration int `json:"duration,omitempty"`
+}
+
+// Scope is the resources a guarantee term applies on (e.g. instances or
+// endpoints). If not empty, the guarantee term is evaluated once per member of
+// the scope; each member has its own metric values, assessment information
+// and violations (see Guarantee.Members). A single string is accepted as a
+// scope of one member.
+type Scope []string
+
+// UnmarshalJSON implements json.Unmarshaler, so that the legacy form of a scope
+// (a single string) is decoded as a scope of one member.
+func (s *Scope) UnmarshalJSON(data []byte) error {
+	var member string
+	if err := json.Unmarshal(data, &member); err == nil {
+		*s = scopeOf(member)
+		return nil
+	}
+	var members []string
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*s = Scope(members)
+	return nil
+}
+
+// SetBSON implements bson.Setter, so that the scopes stored in the legacy
+// form (a single string) are decoded as a scope of one member.
+func (s *Scope) SetBSON(raw bson.Raw) error {
+	if raw.Kind == bsonString {
+		var member string
+		if err := raw.Unmarshal(&member); err != nil {
+			return err
+		}
+		*s = scopeOf(member)
+		return nil
+	}
+	var members []string
+	if err := raw.Unmarshal(&members); err != nil {
+		return err
+	}
+	*s = Scope(members)
+	return nil
+}
+
+// BSON kinds of the elements decoded by the bson.Setter implementations
+const (
+	bsonString   = 0x02
+	bsonDocument = 0x03
+)
 
-// Schedule is the frequency a guarantee term is evaluated
+func scopeOf(member string) Scope {
+	if member == "" {
+		return nil
+	}
+	return Scope{member}
+}
+
+// Schedule is the frequency a guarantee term is evaluated, expressed as an
+// ISO-8601 duration (e.g. PT30M, P1D, P1M). If empty, the guarantee term is
+// evaluated on every assessment.
 type Schedule string
 
 // PenaltyDef is the struct that represents a penalty in case of an SLO violation
@@ -282,25 +721,121 @@ func (v MetricValue) String() string {
 // Violation is generated when a guarantee term is not fulfilled
 // swagger:model
 type Violation struct {
-	Id          string        `json:"id"`
+	Id          string        `json:"id" bson:"_id"`
 	AgreementId string        `json:"agreement_id"`
 	Guarantee   string        `json:"guarantee"`
 	Datetime    time.Time     `json:"datetime"`
 	Constraint  string        `json:"constraint"`
 	Values      []MetricValue `json:"values"`
+	// Member is the scope member of the guarantee term that was violated, if any
+	Member string `json:"member,omitempty"`
+	// Backfilled is true if the violation was raised by a re-assessment of a past
+	// interval instead of by the periodic assessment
+	Backfilled bool `json:"backfilled,omitempty"`
+	// NoData is true if the violation was raised because the guarantee term
+	// had no fresh monitoring data (see Guarantee.OnNoData)
+	NoData bool `json:"no_data,omitempty"`
+	// Maintenance is the id of the maintenance window the violation was raised in,
+	// if the window tags the violations (see MaintenanceWindow)
+	Maintenance string `json:"maintenance,omitempty"`
+}
+
+// ViolationQuery contains the filters to retrieve a list of violations.
+//
+// Empty fields are not taken into account. The time interval is [From, To).
+// swagger:ignore
+type ViolationQuery struct {
+	AgreementId string
+	Guarantee   string
+	ProviderId  string
+	ClientId    string
+	From        time.Time
+	To          time.Time
+}
+
+// Incident groups the consecutive violations of a guarantee term. An incident
+// opens on the first violation and closes when the constraint holds again.
+// swagger:model
+type Incident struct {
+	Id          string     `json:"id" bson:"_id"`
+	AgreementId string     `json:"agreement_id"`
+	Guarantee   string     `json:"guarantee"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"`
+	// Duration is the number of seconds between Start and End; zero if open
+	Duration   float64  `json:"duration"`
+	Violations []string `json:"violations"`
+	// Member is the scope member of the guarantee term, if any
+	Member string `json:"member,omitempty"`
+}
+
+// IncidentQuery contains the filters to retrieve a list of incidents.
+//
+// Empty fields are not taken into account. The time interval [From, To) is
+// applied on the incident start.
+// swagger:ignore
+type IncidentQuery struct {
+	AgreementId string
+	Guarantee   string
+	OnlyOpen    bool
+	From        time.Time
+	To          time.Time
+}
+
+// MaintenanceWindow is a planned downtime of an agreement or, if ProviderId is set
+// instead, of all the agreements of a provider. The points evaluated during an active
+// window do not count, and the violations raised are discarded or tagged, according
+// to Action.
+//
+// A one-off window is active in [Start, End). A recurring window repeats the interval
+// every Recurrence (an ISO-8601 duration, not shorter than the window) after Start,
+// and until Until if set.
+// swagger:model
+type MaintenanceWindow struct {
+	Id          string            `json:"id" bson:"_id"`
+	AgreementId string            `json:"agreement_id,omitempty"`
+	ProviderId  string            `json:"provider_id,omitempty"`
+	Start       time.Time         `json:"start"`
+	End         time.Time         `json:"end"`
+	Recurrence  Schedule          `json:"recurrence,omitempty"`
+	Until       *time.Time        `json:"until,omitempty"`
+	Action      MaintenanceAction `json:"action,omitempty"`
+	Description string            `json:"description,omitempty"`
+}
+
+// MaintenanceWindowQuery contains the filters to retrieve a list of maintenance windows.
+//
+// Empty fields are not taken into account.
+// swagger:ignore
+type MaintenanceWindowQuery struct {
+	AgreementId string
+	ProviderId  string
 }
 
 // Penalty is generated when a guarantee term is violated is the term has
 // PenaltyDefs associated.
 // swagger:model
 type Penalty struct {
-	Id          string     `json:"id"`
+	Id          string     `json:"id" bson:"_id"`
 	AgreementId string     `json:"agreement_id"`
 	Guarantee   string     `json:"guarantee"`
+	ViolationId string     `json:"violation_id"`
 	Datetime    time.Time  `json:"datetime"`
 	Definition  PenaltyDef `json:"definition"`
 }
 
+// PenaltyQuery contains the filters to retrieve a list of penalties.
+//
+// Empty fields are not taken into account. The time interval is [From, To).
+// swagger:ignore
+type PenaltyQuery struct {
+	AgreementId string
+	Guarantee   string
+	ViolationId string
+	From        time.Time
+	To          time.Time
+}
+
 // GetId returns the id of an template
 func (t *Template) GetId() string {
 	return t.Id
@@ -375,6 +910,24 @@ func (as *Assessment) GetGuarantee(name string) AssessmentGuarantee {
 	return as.Guarantees[name]
 }
 
+// SetStatus sets the status of an agreement, setting StatusChange to t if the
+// status changes
+func (as *Assessment) SetStatus(s Status, t time.Time) {
+	if as.Status != s {
+		as.Status = s
+		as.StatusChange = &t
+	}
+}
+
+// SetStatus sets the status of a guarantee term, setting StatusChange to t if the
+// status changes
+func (ag *AssessmentGuarantee) SetStatus(s Status, t time.Time) {
+	if ag.Status != s {
+		ag.Status = s
+		ag.StatusChange = &t
+	}
+}
+
 // Validate validates the consistency of a Details entity
 func (t *Details) Validate(val Validator, mode ValidationMode) []error {
 	return val.ValidateDetails(t, mode)
@@ -393,11 +946,158 @@ func (t *Details) GetVariable(varname string) (result Variable, ok bool) {
 	return Variable{Name: varname, Metric: varname}, false
 }
 
+// GetGuarantee returns the guarantee term with name "name".
+func (t *Details) GetGuarantee(name string) (result Guarantee, ok bool) {
+	for _, gt := range t.Guarantees {
+		if name == gt.Name {
+			return gt, true
+		}
+	}
+	return Guarantee{}, false
+}
+
+// IsValid returns if t is one of AggregationTypes or a percentile.
+func (t AggregationType) IsValid() bool {
+	for _, valid := range AggregationTypes {
+		if t == valid {
+			return true
+		}
+	}
+	_, ok := t.Percentile()
+	return ok
+}
+
+// IsValid returns if t is one of InterpolationTypes.
+func (t InterpolationType) IsValid() bool {
+	for _, valid := range InterpolationTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid returns if s is one of Statuses.
+func (s Status) IsValid() bool {
+	return s.severity() >= 0
+}
+
+// Worse returns if s is worse than other (see Statuses). The empty status is better
+// than any other.
+func (s Status) Worse(other Status) bool {
+	return s.severity() > other.severity()
+}
+
+func (s Status) severity() int {
+	for i, valid := range Statuses {
+		if s == valid {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsValid returns if t is one of MetricTypes.
+func (t MetricType) IsValid() bool {
+	for _, valid := range MetricTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid returns if p is one of NoDataPolicies.
+func (p NoDataPolicy) IsValid() bool {
+	for _, valid := range NoDataPolicies {
+		if p == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Percentile returns the percentile of an aggregation type of the form "pNN"
+// (e.g. p95, p99, p99.9), in the range (0, 100]. ok is false if the type is not
+// a percentile.
+func (t AggregationType) Percentile() (p float64, ok bool) {
+	s := string(t)
+	if len(s) < 2 || s[0] != 'p' {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(s[1:], 64)
+	if err != nil || p <= 0 || p > 100 || strings.ContainsAny(s[1:], "eE+-") {
+		return 0, false
+	}
+	return p, true
+}
+
+// IsViolated returns if a failing streak exceeds the tolerance, given the time
+// of the last failing point. A nil tolerance tolerates no failing points.
+func (t *Tolerance) IsViolated(streak FailingStreak, last time.Time) bool {
+	if t == nil {
+		return true
+	}
+	if t.Points > 0 && streak.Points < t.Points {
+		return false
+	}
+	if t.Duration > 0 && last.Sub(streak.Since) < time.Duration(t.Duration)*time.Second {
+		return false
+	}
+	return true
+}
+
 // Validate validates the consistency of a Guarantee entity
 func (g *Guarantee) Validate(val Validator, mode ValidationMode) []error {
 	return val.ValidateGuarantee(g, mode)
 }
 
+// Members returns the guarantee terms to be evaluated for the scope of g: a copy
+// of g per scope member, whose Scope contains only that member. If the scope is
+// empty, it returns g.
+func (g Guarantee) Members() []Guarantee {
+	if len(g.Scope) == 0 {
+		return []Guarantee{g}
+	}
+	result := make([]Guarantee, 0, len(g.Scope))
+	for _, member := range g.Scope {
+		gt := g
+		gt.Scope = Scope{member}
+		result = append(result, gt)
+	}
+	return result
+}
+
+// Member returns the scope member a guarantee term is evaluated for; i.e., the
+// member of the scope if it has only one member (see Members), or "" otherwise.
+func (g *Guarantee) Member() string {
+	if len(g.Scope) == 1 {
+		return g.Scope[0]
+	}
+	return ""
+}
+
+// AssessmentKey returns the key of the assessment information of a guarantee
+// term in Assessment.Guarantees: the name of the term, or name[member] if the
+// term is evaluated for a scope member.
+func (g *Guarantee) AssessmentKey() string {
+	return MemberKey(g.Name, g.Member())
+}
+
+// MemberKey returns the key of name for a scope member: name[member], or name
+// if member is empty.
+func MemberKey(name, member string) string {
+	if member == "" {
+		return name
+	}
+	return name + "[" + member + "]"
+}
+
+// Validate validates the consistency of a Variable
+func (v *Variable) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidateVariable(v, mode)
+}
+
 // GetId returns the Id of a violation
 func (v *Violation) GetId() string {
 	return v.Id
@@ -408,6 +1108,131 @@ func (v *Violation) Validate(val Validator, mode ValidationMode) []error {
 	return val.ValidateViolation(v, mode)
 }
 
+// MatchTime returns if t is in the [From, To) interval of the query
+func (q *ViolationQuery) MatchTime(t time.Time) bool {
+	return inInterval(t, q.From, q.To)
+}
+
+// GetId returns the Id of a penalty
+func (p *Penalty) GetId() string {
+	return p.Id
+}
+
+// Validate validates the consistency of a Penalty entity
+func (p *Penalty) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidatePenalty(p, mode)
+}
+
+// GetId returns the Id of an incident
+func (i *Incident) GetId() string {
+	return i.Id
+}
+
+// Validate validates the consistency of an Incident entity
+func (i *Incident) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidateIncident(i, mode)
+}
+
+// IsOpen returns if the incident has not been closed yet
+func (i *Incident) IsOpen() bool {
+	return i.End == nil
+}
+
+// Close closes the incident at time end
+func (i *Incident) Close(end time.Time) {
+	i.End = &end
+	i.Duration = end.Sub(i.Start).Seconds()
+}
+
+// MatchTime returns if t is in the [From, To) interval of the query
+func (q *IncidentQuery) MatchTime(t time.Time) bool {
+	return inInterval(t, q.From, q.To)
+}
+
+// GetId returns the Id of a maintenance window
+func (w *MaintenanceWindow) GetId() string {
+	return w.Id
+}
+
+// Validate validates the consistency of a MaintenanceWindow entity
+func (w *MaintenanceWindow) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidateMaintenanceWindow(w, mode)
+}
+
+// AppliesTo returns if the window is a maintenance window of the agreement a, i.e., it
+// is set on the agreement or on the provider of the agreement.
+func (w *MaintenanceWindow) AppliesTo(a *Agreement) bool {
+	if w.AgreementId != "" {
+		return w.AgreementId == a.Id
+	}
+	return w.ProviderId != "" && w.ProviderId == a.Details.Provider.Id
+}
+
+// IsActive returns if t falls inside the window or, if the window is recurring,
+// inside any of its occurrences. The n-th occurrence starts at Start plus n times
+// Recurrence; as the recurrence is not shorter than the window, only the last
+// occurrence started at t may be active. An invalid recurrence is ignored.
+func (w *MaintenanceWindow) IsActive(t time.Time) bool {
+	if t.Before(w.Start) {
+		return false
+	}
+	start := w.Start
+	if w.Recurrence != "" {
+		if last, err := w.Recurrence.last(w.Start, t); err == nil {
+			start = last
+		}
+	}
+	if w.Until != nil && !start.Before(*w.Until) {
+		return false
+	}
+	return t.Before(start.Add(w.End.Sub(w.Start)))
+}
+
+// GetAction returns the action of the window, which defaults to MaintenanceSkip
+func (w *MaintenanceWindow) GetAction() MaintenanceAction {
+	if w.Action == "" {
+		return MaintenanceSkip
+	}
+	return w.Action
+}
+
+// IsValid returns if a is one of MaintenanceActions.
+func (a MaintenanceAction) IsValid() bool {
+	for _, valid := range MaintenanceActions {
+		if a == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns the first window in ws that applies to the agreement a and is active
+// at t, or nil if there is none.
+func (ws MaintenanceWindows) Active(a *Agreement, t time.Time) *MaintenanceWindow {
+	for i := range ws {
+		if ws[i].AppliesTo(a) && ws[i].IsActive(t) {
+			return &ws[i]
+		}
+	}
+	return nil
+}
+
+// MatchTime returns if t is in the [From, To) interval of the query
+func (q *PenaltyQuery) MatchTime(t time.Time) bool {
+	return inInterval(t, q.From, q.To)
+}
+
+// inInterval returns if t is in [from, to). A zero from or to means an open interval.
+func inInterval(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
 // Normalize returns an always valid state: any different value from contained in States is STOPPED.
 func (s State) Normalize() State {
 	return normalizeState(s)
@@ -424,3 +1249,19 @@ type Agreements []Agreement
 // Templates is the type of an slice of Template
 // swagger:model
 type Templates []Template
+
+// Violations is the type of an slice of Violation
+// swagger:model
+type Violations []Violation
+
+// Penalties is the type of an slice of Penalty
+// swagger:model
+type Penalties []Penalty
+
+// Incidents is the type of an slice of Incident
+// swagger:model
+type Incidents []Incident
+
+// MaintenanceWindows is the type of an slice of MaintenanceWindow
+// swagger:model
+type MaintenanceWindows []MaintenanceWindow
@@ -24,6 +24,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/globalsign/mgo/bson"
 )
 
 func TestMain(m *testing.M) {
@@ -74,6 +76,290 @@ func TestGuarantee(t *testing.T) {
 	g = Guarantee{Name: "name", Constraint: ""}
 	checkNumber(t, &g, 1)
 
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Schedule: "P1D"}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Schedule: "daily"}
+	checkNumber(t, &g, 1)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Tolerance: &Tolerance{Points: 3, Duration: 60}}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Tolerance: &Tolerance{Points: -1}}
+	checkNumber(t, &g, 1)
+
+	g = Guarantee{Name: "name", Constraint: "abs(a) < 10", Warning: "between(a, 0, 5) || weekday() == 0"}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "sqrt(a) < 10", Warning: "log(a) < 1"}
+	checkNumber(t, &g, 2)
+
+	g = Guarantee{Name: "name", Constraint: "abs(a)", Warning: "a - 5"}
+	checkNumber(t, &g, 2)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Objective: &Objective{Target: 0.99, Period: "P30D"}}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Objective: &Objective{Target: 99, Period: "monthly"}}
+	checkNumber(t, &g, 2)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10",
+		Objective: &Objective{Target: 0.999, Calendar: &Calendar{Period: MONTH, TimeZone: "Europe/Madrid"}}}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10",
+		Objective: &Objective{Target: 0.999, Period: "P1M", Calendar: &Calendar{Period: "year"}}}
+	checkNumber(t, &g, 2)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", OnNoData: NoDataViolation, Staleness: 300}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", OnNoData: "fail", Staleness: -1}
+	checkNumber(t, &g, 2)
+}
+
+func TestStatus(t *testing.T) {
+	if !VIOLATED.IsValid() || Status("ok").IsValid() || Status("").IsValid() {
+		t.Errorf("Unexpected status validation")
+	}
+	if !VIOLATED.Worse(UNKNOWN) || !UNKNOWN.Worse(WARNING) || !WARNING.Worse(FULFILLED) ||
+		!FULFILLED.Worse("") || FULFILLED.Worse(FULFILLED) {
+		t.Errorf("Unexpected status order")
+	}
+
+	t0 := time.Now()
+	ag := AssessmentGuarantee{}
+	ag.SetStatus(FULFILLED, t0)
+	ag.SetStatus(FULFILLED, t0.Add(time.Minute))
+	if ag.Status != FULFILLED || ag.StatusChange == nil || !ag.StatusChange.Equal(t0) {
+		t.Errorf("Unexpected status change: %v at %v", ag.Status, ag.StatusChange)
+	}
+	as := Assessment{}
+	as.SetStatus(VIOLATED, t0)
+	if as.Status != VIOLATED || as.StatusChange == nil || !as.StatusChange.Equal(t0) {
+		t.Errorf("Unexpected status change: %v at %v", as.Status, as.StatusChange)
+	}
+}
+
+func TestGuaranteeMembers(t *testing.T) {
+	g := Guarantee{Name: "name", Constraint: "a LT 10"}
+	if members := g.Members(); len(members) != 1 || members[0].AssessmentKey() != "name" {
+		t.Errorf("Unexpected members of unscoped guarantee: %v", members)
+	}
+
+	g.Scope = Scope{"m1", "m2"}
+	checkNumber(t, &g, 0)
+	if g.Member() != "" || g.AssessmentKey() != "name" {
+		t.Errorf("Unexpected member of guarantee %v: %s", g, g.Member())
+	}
+	members := g.Members()
+	if len(members) != 2 {
+		t.Fatalf("Unexpected members of guarantee %v: %v", g, members)
+	}
+	if members[1].Member() != "m2" || members[1].AssessmentKey() != "name[m2]" {
+		t.Errorf("Unexpected member: %v", members[1])
+	}
+
+	g.Scope = Scope{"m1", "", "m1"}
+	checkNumber(t, &g, 2)
+}
+
+func TestScopeDecoding(t *testing.T) {
+	expected := map[string]Scope{
+		`"m1"`:        {"m1"},
+		`""`:          nil,
+		`["m1","m2"]`: {"m1", "m2"},
+	}
+	for input, scope := range expected {
+		var g Guarantee
+		if err := json.Unmarshal([]byte(`{"scope":`+input+`}`), &g); err != nil {
+			t.Errorf("Unexpected error decoding %s: %v", input, err)
+		}
+		if !reflect.DeepEqual(g.Scope, scope) {
+			t.Errorf("Unexpected scope decoded from %s. Expected: %v. Actual: %v", input, scope, g.Scope)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"scope":1}`), &Guarantee{}); err == nil {
+		t.Errorf("Expected error decoding a numeric scope")
+	}
+
+	for _, stored := range []interface{}{"m1", []string{"m1"}} {
+		data, err := bson.Marshal(bson.M{"name": "gt", "scope": stored})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var g Guarantee
+		if err := bson.Unmarshal(data, &g); err != nil {
+			t.Errorf("Unexpected error decoding %v: %v", stored, err)
+		}
+		if !reflect.DeepEqual(g.Scope, Scope{"m1"}) {
+			t.Errorf("Unexpected scope decoded from %v: %v", stored, g.Scope)
+		}
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	t0 := time.Now()
+	streak := FailingStreak{Points: 2, Since: t0}
+
+	var nilTolerance *Tolerance
+	if !nilTolerance.IsViolated(streak, t0) {
+		t.Errorf("Nil tolerance must be always violated")
+	}
+	tolerance := &Tolerance{Points: 3}
+	if tolerance.IsViolated(streak, t0) {
+		t.Errorf("Tolerance %v must not be violated by streak %v", *tolerance, streak)
+	}
+	streak.Points = 3
+	if !tolerance.IsViolated(streak, t0) {
+		t.Errorf("Tolerance %v must be violated by streak %v", *tolerance, streak)
+	}
+	tolerance.Duration = 60
+	if tolerance.IsViolated(streak, t0.Add(59*time.Second)) {
+		t.Errorf("Tolerance %v must not be violated after 59s", *tolerance)
+	}
+	if !tolerance.IsViolated(streak, t0.Add(60*time.Second)) {
+		t.Errorf("Tolerance %v must be violated after 60s", *tolerance)
+	}
+}
+
+func TestAggregationType(t *testing.T) {
+	valid := []AggregationType{NONE, AVERAGE, MIN, MAX, SUM, COUNT, MEDIAN, RATE, INCREASE, "p95", "p99", "p99.9", "p100"}
+	for _, aggtype := range valid {
+		if !aggtype.IsValid() {
+			t.Errorf("Aggregation type %s must be valid", aggtype)
+		}
+	}
+	invalid := []AggregationType{"", "avg", "p", "p0", "p101", "p-5", "p9e1", "pNN"}
+	for _, aggtype := range invalid {
+		if aggtype.IsValid() {
+			t.Errorf("Aggregation type %s must not be valid", aggtype)
+		}
+	}
+	if p, ok := AggregationType("p99.9").Percentile(); !ok || p != 99.9 {
+		t.Errorf("Unexpected percentile of p99.9: %v", p)
+	}
+}
+
+func TestVariable(t *testing.T) {
+	v := Variable{Name: "name", Metric: "metric", Aggregation: &Aggregation{Type: "p95", Window: 60}}
+	checkNumber(t, &v, 0)
+
+	v = Variable{Name: "", Metric: "metric", Aggregation: &Aggregation{Type: "perc95", Window: -1}}
+	checkNumber(t, &v, 3)
+
+	calendar := Variable{Name: "name", Metric: "metric", Aggregation: &Aggregation{Type: AVERAGE, Calendar: &Calendar{Period: DAY}}}
+	checkNumber(t, &calendar, 0)
+
+	calendar.Aggregation = &Aggregation{Type: AVERAGE, Window: 60, Calendar: &Calendar{Period: DAY, TimeZone: "Mars/Olympus"}}
+	checkNumber(t, &calendar, 2)
+
+	counter := Variable{Name: "name", Metric: "metric", Type: COUNTER, Aggregation: &Aggregation{Type: INCREASE, Window: 60}}
+	checkNumber(t, &counter, 0)
+	if !counter.IsCounter() {
+		t.Errorf("Variable %v must be a counter", counter)
+	}
+
+	counter.Type = "meter"
+	checkNumber(t, &counter, 1)
+	if counter.IsCounter() {
+		t.Errorf("Variable %v must not be a counter", counter)
+	}
+
+	histogram := Variable{Name: "name", Metric: "metric", Type: HISTOGRAM}
+	checkNumber(t, &histogram, 1)
+	histogram.Aggregation = &Aggregation{Type: AVERAGE, Window: 60}
+	checkNumber(t, &histogram, 1)
+	for _, agg := range []AggregationType{"p99", MEDIAN, COUNT} {
+		histogram.Aggregation = &Aggregation{Type: agg, Window: 60}
+		checkNumber(t, &histogram, 0)
+	}
+	if !histogram.IsHistogram() || histogram.IsCounter() {
+		t.Errorf("Variable %v must be a histogram and not a counter", histogram)
+	}
+	histogram.Type = COUNTERHISTOGRAM
+	if !histogram.IsHistogram() || !histogram.IsCounter() {
+		t.Errorf("Variable %v must be a histogram of counters", histogram)
+	}
+
+	d := Details{
+		Id:        "id",
+		Name:      "name",
+		Provider:  pr,
+		Client:    cl,
+		Variables: []Variable{v},
+	}
+	checkNumber(t, &d, 3)
+}
+
+func TestRatio(t *testing.T) {
+	hour := &Aggregation{Type: SUM, Window: 3600}
+	v := Variable{Name: "availability", Interpolation: &Interpolation{Type: LINEAR},
+		Ratio: &Ratio{Good: RatioMetric{Metric: "ok", Aggregation: hour}, Total: RatioMetric{Metric: "requests"}}}
+	checkNumber(t, &v, 0)
+
+	if !v.IsRatio() || v.Ratio.GetScale() != 1 {
+		t.Errorf("Unexpected ratio %v", v.Ratio)
+	}
+	good, total := v.RatioVariables()
+	expected := Variable{Name: "availability.good", Metric: "ok", Aggregation: hour, Interpolation: v.Interpolation}
+	if good != expected {
+		t.Errorf("Unexpected good variable. Expected: %v. Actual: %v", expected, good)
+	}
+	expected = Variable{Name: "availability.total", Metric: "requests", Interpolation: v.Interpolation}
+	if total != expected {
+		t.Errorf("Unexpected total variable. Expected: %v. Actual: %v", expected, total)
+	}
+
+	v = Variable{Name: "availability", Metric: "metric", Aggregation: hour, Type: COUNTER,
+		Ratio: &Ratio{Good: RatioMetric{Metric: "ok", Aggregation: &Aggregation{Type: "perc95"}, Type: "meter"}, Scale: -1}}
+	checkNumber(t, &v, 7)
+
+	v.Ratio = &Ratio{Good: RatioMetric{Metric: "ok", Type: COUNTER}, Total: RatioMetric{Metric: "requests"}}
+	if good, total := v.RatioVariables(); !good.IsCounter() || total.IsCounter() {
+		t.Errorf("Unexpected types of ratio variables: %v, %v", good, total)
+	}
+
+	if plain := (Variable{Name: "name", Metric: "metric"}); plain.IsRatio() {
+		t.Errorf("Variable %v must not be a ratio", plain)
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	v := Variable{Name: "name", Metric: "metric", Interpolation: &Interpolation{Type: LINEAR, Delta: delta(5)}}
+	checkNumber(t, &v, 0)
+
+	v = Variable{Name: "name", Metric: "metric", Interpolation: &Interpolation{Type: "cubic", Delta: delta(-1)}}
+	checkNumber(t, &v, 2)
+
+	d := Details{Id: "id", Name: "name", Provider: pr, Client: cl, Interpolation: &Interpolation{Type: "cubic"}}
+	checkNumber(t, &d, 1)
+
+	var nilInterpolation *Interpolation
+	if i := nilInterpolation.Merge(DefaultInterpolation); i != DefaultInterpolation {
+		t.Errorf("Unexpected merge of nil interpolation: %v", i)
+	}
+	i := &Interpolation{Type: STRICT}
+	if merged := i.Merge(DefaultInterpolation); merged.Type != STRICT || merged.GetDelta() != DefaultInterpolation.GetDelta() {
+		t.Errorf("Unexpected merged interpolation: %v", merged)
+	}
+	i = &Interpolation{Delta: delta(2)}
+	if merged := i.Merge(DefaultInterpolation); merged.Type != DefaultInterpolation.Type || merged.GetDelta() != 2 {
+		t.Errorf("Unexpected merged interpolation: %v", merged)
+	}
+	i = &Interpolation{Delta: delta(0)}
+	if merged := i.Merge(DefaultInterpolation); merged.Delta == nil || merged.GetDelta() != 0 {
+		t.Errorf("Unexpected merged interpolation with zero delta: %v", merged)
+	}
+
+	var decoded Interpolation
+	if err := json.Unmarshal([]byte(`{"delta":0}`), &decoded); err != nil || decoded.Delta == nil {
+		t.Errorf("Zero delta not decoded: %v %v", decoded, err)
+	}
+}
+
+func delta(d float64) *float64 {
+	return &d
 }
 
 func TestDetails(t *testing.T) {
@@ -92,6 +378,15 @@ func TestDetails(t *testing.T) {
 	at = Details{Id: "id", Name: "name", Provider: pr}
 	checkNumber(t, &at, 2)
 
+	at = Details{Id: "id", Name: "name", Provider: pr, Client: cl, Schedule: "PT10S"}
+	checkNumber(t, &at, 0)
+
+	at = Details{Id: "id", Name: "name", Provider: pr, Client: cl, Schedule: "10s"}
+	checkNumber(t, &at, 1)
+
+	at = Details{Id: "id", Name: "name", Provider: pr, Client: cl, Schedule: "PT0S"}
+	checkNumber(t, &at, 1)
+
 	at = Details{
 		Id:       "id",
 		Name:     "name",
@@ -367,6 +662,112 @@ func TestViolationSerialization(t *testing.T) {
 	checkNumber(t, &v, 0)
 }
 
+func TestPenalty(t *testing.T) {
+	var p = Penalty{}
+	checkNumber(t, &p, 6)
+	if p.GetId() != p.Id {
+		t.Errorf("Penalty.Id and Penalty.GetId() do not match")
+	}
+
+	p = Penalty{
+		Id:          "p-id",
+		AgreementId: "a-id",
+		Guarantee:   "gt-name",
+		ViolationId: "v-id",
+		Datetime:    time.Now(),
+		Definition:  PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+	}
+	checkNumber(t, &p, 0)
+}
+
+func TestIncident(t *testing.T) {
+	var i = Incident{}
+	checkNumber(t, &i, 4)
+	if i.GetId() != i.Id {
+		t.Errorf("Incident.Id and Incident.GetId() do not match")
+	}
+
+	start := time.Now()
+	i = Incident{
+		Id:          "i-id",
+		AgreementId: "a-id",
+		Guarantee:   "gt-name",
+		Start:       start,
+	}
+	checkNumber(t, &i, 0)
+	if !i.IsOpen() {
+		t.Errorf("Incident %v must be open", i)
+	}
+
+	i.Close(start.Add(90 * time.Second))
+	checkNumber(t, &i, 0)
+	if i.IsOpen() {
+		t.Errorf("Incident %v must be closed", i)
+	}
+	if i.Duration != 90 {
+		t.Errorf("Unexpected incident duration. Expected: 90; Actual: %v", i.Duration)
+	}
+
+	i.Close(start.Add(-time.Second))
+	checkNumber(t, &i, 1)
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	start := time.Date(2019, 1, 7, 2, 0, 0, 0, time.UTC) // a Monday
+	w := MaintenanceWindow{Id: "w", AgreementId: "a", Start: start, End: start.Add(time.Hour)}
+	checkNumber(t, &w, 0)
+	if w.GetId() != w.Id {
+		t.Errorf("MaintenanceWindow.Id and MaintenanceWindow.GetId() do not match")
+	}
+
+	checkNumber(t, &MaintenanceWindow{Id: "w", Start: start, End: start, Recurrence: "weekly", Action: "drop"}, 4)
+	until := start
+	checkNumber(t, &MaintenanceWindow{Id: "w", AgreementId: "a", ProviderId: "p", Start: start,
+		End: start.Add(time.Hour), Until: &until}, 2)
+
+	if !w.IsActive(start) || !w.IsActive(start.Add(59*time.Minute)) {
+		t.Errorf("Window %v must be active", w)
+	}
+	if w.IsActive(start.Add(-time.Second)) || w.IsActive(start.Add(time.Hour)) ||
+		w.IsActive(start.Add(7*24*time.Hour)) {
+		t.Errorf("Window %v must not be active", w)
+	}
+
+	w.Recurrence = "P1W"
+	if !w.IsActive(start.Add(14*24*time.Hour + 30*time.Minute)) {
+		t.Errorf("Recurring window %v must be active", w)
+	}
+	if w.IsActive(start.Add(14*24*time.Hour + 90*time.Minute)) {
+		t.Errorf("Recurring window %v must not be active", w)
+	}
+	if !w.IsActive(start.Add(5200*7*24*time.Hour + 30*time.Minute)) {
+		t.Errorf("Recurring window %v must be active after 5200 weeks", w)
+	}
+	checkNumber(t, &MaintenanceWindow{Id: "w", AgreementId: "a", Start: start,
+		End: start.Add(time.Hour), Recurrence: "PT30M"}, 1)
+	until = start.Add(7 * 24 * time.Hour)
+	w.Until = &until
+	if w.IsActive(start.Add(14*24*time.Hour + 30*time.Minute)) {
+		t.Errorf("Recurring window %v must not be active after %v", w, until)
+	}
+
+	a := Agreement{Id: "a", Details: Details{Provider: Provider{Id: "p"}}}
+	pw := MaintenanceWindow{Id: "pw", ProviderId: "p", Start: start, End: start.Add(time.Hour), Action: MaintenanceTag}
+	if !w.AppliesTo(&a) || !pw.AppliesTo(&a) {
+		t.Errorf("Windows must apply to agreement %v", a)
+	}
+	if w.GetAction() != MaintenanceSkip || pw.GetAction() != MaintenanceTag {
+		t.Errorf("Unexpected window actions")
+	}
+	ws := MaintenanceWindows{{Id: "other", AgreementId: "b", Start: start, End: start.Add(time.Hour)}, pw}
+	if active := ws.Active(&a, start); active == nil || active.Id != "pw" {
+		t.Errorf("Unexpected active window: %v", active)
+	}
+	if active := ws.Active(&a, start.Add(time.Hour)); active != nil {
+		t.Errorf("Unexpected active window: %v", active)
+	}
+}
+
 type valError string
 
 func (e valError) Error() string {
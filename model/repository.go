@@ -137,6 +137,102 @@ type IRepository interface {
 	 */
 	GetViolation(id string) (*Violation, error)
 
+	/*
+	 * GetViolations returns the violations that match the filters in q,
+	 * sorted by Datetime.
+	 *
+	 * The list is empty when no violation matches the query;
+	 * error != nil on error
+	 */
+	GetViolations(q ViolationQuery) (Violations, error)
+
+	/*
+	 * CreatePenalty stores a new Penalty.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Penalty already exists
+	 */
+	CreatePenalty(p *Penalty) (*Penalty, error)
+
+	/*
+	 * GetPenalties returns the penalties that match the filters in q,
+	 * sorted by Datetime.
+	 *
+	 * The list is empty when no penalty matches the query;
+	 * error != nil on error
+	 */
+	GetPenalties(q PenaltyQuery) (Penalties, error)
+
+	/*
+	 * CreateIncident stores a new Incident.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Incident already exists
+	 */
+	CreateIncident(i *Incident) (*Incident, error)
+
+	/*
+	 * UpdateIncident updates the information of an already saved instance
+	 * of an incident
+	 */
+	UpdateIncident(i *Incident) (*Incident, error)
+
+	/*
+	 * GetIncident returns the Incident identified by id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Incident is not found
+	 */
+	GetIncident(id string) (*Incident, error)
+
+	/*
+	 * GetIncidents returns the incidents that match the filters in q,
+	 * sorted by Start.
+	 *
+	 * The list is empty when no incident matches the query;
+	 * error != nil on error
+	 */
+	GetIncidents(q IncidentQuery) (Incidents, error)
+
+	/*
+	 * CreateMaintenanceWindow stores a new MaintenanceWindow.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the MaintenanceWindow already exists
+	 */
+	CreateMaintenanceWindow(w *MaintenanceWindow) (*MaintenanceWindow, error)
+
+	/*
+	 * UpdateMaintenanceWindow updates the information of an already saved instance
+	 * of a maintenance window
+	 */
+	UpdateMaintenanceWindow(w *MaintenanceWindow) (*MaintenanceWindow, error)
+
+	/*
+	 * GetMaintenanceWindow returns the MaintenanceWindow identified by id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the MaintenanceWindow is not found
+	 */
+	GetMaintenanceWindow(id string) (*MaintenanceWindow, error)
+
+	/*
+	 * GetMaintenanceWindows returns the maintenance windows that match the filters in q,
+	 * sorted by Start.
+	 *
+	 * The list is empty when no window matches the query;
+	 * error != nil on error
+	 */
+	GetMaintenanceWindows(q MaintenanceWindowQuery) (MaintenanceWindows, error)
+
+	/*
+	 * DeleteMaintenanceWindow deletes from the repository the MaintenanceWindow whose id is w.Id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the MaintenanceWindow does not exist.
+	 */
+	DeleteMaintenanceWindow(w *MaintenanceWindow) error
+
 	/*
 	 * UpdateAgreementState changes the state of an Agreement.
 	 *
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// scheduleRegexp matches an ISO-8601 duration: PnYnMnWnDTnHnMnS
+var scheduleRegexp = regexp.MustCompile(
+	`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// scheduleDuration is a parsed Schedule. Years, months and days are kept apart
+// from the time part, as their length depends on the calendar.
+type scheduleDuration struct {
+	years  int
+	months int
+	days   int
+	time   time.Duration
+}
+
+func (s Schedule) parse() (scheduleDuration, error) {
+	var d scheduleDuration
+
+	str := string(s)
+	m := scheduleRegexp.FindStringSubmatch(str)
+	if m == nil || str == "P" || str[len(str)-1] == 'T' {
+		return d, fmt.Errorf("Schedule '%s' is not a valid ISO-8601 duration", str)
+	}
+	n := make([]int, len(m))
+	for i := 1; i < len(m); i++ {
+		if m[i] != "" {
+			n[i], _ = strconv.Atoi(m[i])
+		}
+	}
+	d.years = n[1]
+	d.months = n[2]
+	d.days = n[3]*7 + n[4]
+	d.time = time.Duration(n[5])*time.Hour + time.Duration(n[6])*time.Minute +
+		time.Duration(n[7])*time.Second
+	if d.years == 0 && d.months == 0 && d.days == 0 && d.time == 0 {
+		return d, fmt.Errorf("Schedule '%s' must be a positive duration", str)
+	}
+	return d, nil
+}
+
+// Check returns an error if the schedule is not empty and it is not a valid,
+// positive ISO-8601 duration.
+func (s Schedule) Check() error {
+	if s == "" {
+		return nil
+	}
+	_, err := s.parse()
+	return err
+}
+
+// Next returns the time a guarantee term with this schedule is due to be evaluated
+// again, if it was last evaluated at "last".
+//
+// An empty schedule returns last, i.e., the term is due on every evaluation.
+func (s Schedule) Next(last time.Time) (time.Time, error) {
+	if s == "" {
+		return last, nil
+	}
+	d, err := s.parse()
+	if err != nil {
+		return last, err
+	}
+	return last.AddDate(d.years, d.months, d.days).Add(d.time), nil
+}
+
+// occurrence returns the start of the n-th repetition of the duration from start,
+// i.e., start plus n times the duration.
+func (d scheduleDuration) occurrence(start time.Time, n int) time.Time {
+	return start.AddDate(n*d.years, n*d.months, n*d.days).Add(time.Duration(n) * d.time)
+}
+
+// approximate returns the approximate length of the duration, taking the average
+// length of years and months.
+func (d scheduleDuration) approximate() time.Duration {
+	const day = 24 * time.Hour
+	return time.Duration(d.years)*day*146097/400 + time.Duration(d.months)*day*146097/4800 +
+		time.Duration(d.days)*day + d.time
+}
+
+// last returns the start of the last repetition of the schedule from start that
+// is not after t, where the n-th repetition is start plus n times the schedule.
+// The repetition is computed, not iterated, so that the cost does not depend on
+// the distance from start to t. If t is before start, start is returned.
+func (s Schedule) last(start, t time.Time) (time.Time, error) {
+	d, err := s.parse()
+	if err != nil || !t.After(start) {
+		return start, err
+	}
+	/* the estimation may be off by some repetitions, due to the calendar */
+	n := int(t.Sub(start) / d.approximate())
+	for n > 0 && d.occurrence(start, n).After(t) {
+		n--
+	}
+	for !d.occurrence(start, n+1).After(t) {
+		n++
+	}
+	return d.occurrence(start, n), nil
+}
+
+// IsDue returns if a guarantee term with this schedule, last evaluated at "last",
+// has to be evaluated at "now".
+//
+// A term that has never been evaluated (zero last) is always due. A term with
+// an invalid schedule is always due too, so that it is not silently skipped.
+func (s Schedule) IsDue(last, now time.Time) bool {
+	if last.IsZero() {
+		return true
+	}
+	next, err := s.Next(last)
+	if err != nil {
+		return true
+	}
+	return !now.Before(next)
+}
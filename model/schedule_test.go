@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleCheck(t *testing.T) {
+	for _, s := range []Schedule{"", "PT30S", "PT1H30M", "P1D", "P2W", "P1M", "P1Y2M3DT4H5M6S"} {
+		if err := s.Check(); err != nil {
+			t.Errorf("Unexpected error checking schedule '%s': %v", s, err)
+		}
+	}
+	for _, s := range []Schedule{"P", "PT", "P1DT", "1D", "PT1D", "P1H", "P-1D", "daily", "PT0S", "P0D"} {
+		if err := s.Check(); err == nil {
+			t.Errorf("Expected error checking schedule '%s'", s)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	last := time.Date(2018, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	expected := map[Schedule]time.Time{
+		"":        last,
+		"PT90S":   last.Add(90 * time.Second),
+		"PT1H30M": last.Add(90 * time.Minute),
+		"P1D":     time.Date(2018, time.February, 1, 10, 0, 0, 0, time.UTC),
+		"P1W":     time.Date(2018, time.February, 7, 10, 0, 0, 0, time.UTC),
+		"P1M":     time.Date(2018, time.March, 3, 10, 0, 0, 0, time.UTC),
+		"P1YT1H":  time.Date(2019, time.January, 31, 11, 0, 0, 0, time.UTC),
+	}
+	for s, exp := range expected {
+		next, err := s.Next(last)
+		if err != nil {
+			t.Errorf("Unexpected error in schedule '%s': %v", s, err)
+		}
+		if !next.Equal(exp) {
+			t.Errorf("Unexpected next time for schedule '%s'. Expected: %v. Actual: %v", s, exp, next)
+		}
+	}
+
+	if _, err := Schedule("wrong").Next(last); err == nil {
+		t.Errorf("Expected error in wrong schedule")
+	}
+}
+
+func TestScheduleLast(t *testing.T) {
+	start := time.Date(2018, time.January, 31, 10, 0, 0, 0, time.UTC)
+	expected := map[Schedule]map[time.Time]time.Time{
+		"PT1H": {
+			start.Add(-time.Hour):       start,
+			start:                       start,
+			start.Add(90 * time.Minute): start.Add(time.Hour),
+			start.Add(100000*time.Hour + time.Second): start.Add(100000 * time.Hour),
+			start.Add(100000*time.Hour - time.Second): start.Add(99999 * time.Hour),
+		},
+		"P1M": {
+			time.Date(2018, time.March, 3, 9, 0, 0, 0, time.UTC):    start,
+			time.Date(2018, time.March, 3, 10, 0, 0, 0, time.UTC):   time.Date(2018, time.March, 3, 10, 0, 0, 0, time.UTC),
+			time.Date(2118, time.January, 31, 9, 0, 0, 0, time.UTC): time.Date(2117, time.December, 31, 10, 0, 0, 0, time.UTC),
+		},
+		"P1Y1D": {
+			time.Date(2020, time.February, 1, 10, 0, 0, 0, time.UTC): time.Date(2019, time.February, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}
+	for s, cases := range expected {
+		for at, exp := range cases {
+			if last, err := s.last(start, at); err != nil || !last.Equal(exp) {
+				t.Errorf("Unexpected last repetition of '%s' at %v. Expected: %v. Actual: %v (%v)", s, at, exp, last, err)
+			}
+		}
+	}
+	if _, err := Schedule("wrong").last(start, start.Add(time.Hour)); err == nil {
+		t.Errorf("Expected error in wrong schedule")
+	}
+}
+
+func TestScheduleIsDue(t *testing.T) {
+	last := time.Now()
+	s := Schedule("PT1H")
+
+	if !s.IsDue(time.Time{}, last) {
+		t.Errorf("Never evaluated schedule must be due")
+	}
+	if s.IsDue(last, last.Add(59*time.Minute)) {
+		t.Errorf("Schedule %s must not be due after 59 minutes", s)
+	}
+	if !s.IsDue(last, last.Add(time.Hour)) {
+		t.Errorf("Schedule %s must be due after 1 hour", s)
+	}
+	if !Schedule("").IsDue(last, last) {
+		t.Errorf("Empty schedule must be always due")
+	}
+	if !Schedule("wrong").IsDue(last, last) {
+		t.Errorf("Wrong schedule must be always due")
+	}
+}
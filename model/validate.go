@@ -31,7 +31,11 @@ type Validator interface {
 	ValidateAssessment(as *Assessment, mode ValidationMode) []error
 	ValidateDetails(t *Details, mode ValidationMode) []error
 	ValidateGuarantee(g *Guarantee, mode ValidationMode) []error
+	ValidateVariable(v *Variable, mode ValidationMode) []error
 	ValidateViolation(v *Violation, mode ValidationMode) []error
+	ValidatePenalty(p *Penalty, mode ValidationMode) []error
+	ValidateIncident(i *Incident, mode ValidationMode) []error
+	ValidateMaintenanceWindow(w *MaintenanceWindow, mode ValidationMode) []error
 }
 
 // ValidationMode is the type of possible validations
@@ -151,6 +155,15 @@ func (val DefaultValidator) ValidateDetails(t *Details, mode ValidationMode) []e
 	for _, e := range t.Client.Validate(val, UPDATE) {
 		result = append(result, e)
 	}
+	result = checkInterpolation(t.Interpolation, "Details.Interpolation", result)
+	if err := t.Schedule.Check(); err != nil {
+		result = append(result, err)
+	}
+	for _, v := range t.Variables {
+		for _, e := range v.Validate(val, mode) {
+			result = append(result, e)
+		}
+	}
 	for _, g := range t.Guarantees {
 		for _, e := range g.Validate(val, mode) {
 			result = append(result, e)
@@ -177,15 +190,209 @@ func (val DefaultValidator) ValidateViolation(v *Violation, mode ValidationMode)
 	return result
 }
 
+// ValidatePenalty implements model.Validator.ValidatePenalty
+func (val DefaultValidator) ValidatePenalty(p *Penalty, mode ValidationMode) []error {
+	result := make([]error, 0)
+
+	result = checkEmpty(mode == CREATE && val.externalIDs, p.Id, "Penalty.Id", result)
+	result = checkNotEmpty(p.AgreementId, "Penalty.AgreementId", result)
+	result = checkNotEmpty(p.Guarantee, "Penalty.Guarantee", result)
+	result = checkNotEmpty(p.ViolationId, "Penalty.ViolationId", result)
+	if p.Datetime.IsZero() {
+		result = append(result, fmt.Errorf("%v is not a valid date", p.Datetime))
+	}
+	result = checkNotEmpty(p.Definition.Type, "Penalty.Definition.Type", result)
+
+	return result
+}
+
+// ValidateIncident implements model.Validator.ValidateIncident
+func (val DefaultValidator) ValidateIncident(i *Incident, mode ValidationMode) []error {
+	result := make([]error, 0)
+
+	result = checkEmpty(mode == CREATE && val.externalIDs, i.Id, "Incident.Id", result)
+	result = checkNotEmpty(i.AgreementId, "Incident.AgreementId", result)
+	result = checkNotEmpty(i.Guarantee, "Incident.Guarantee", result)
+	if i.Start.IsZero() {
+		result = append(result, fmt.Errorf("%v is not a valid date", i.Start))
+	}
+	if i.End != nil && i.End.Before(i.Start) {
+		result = append(result, fmt.Errorf("Incident.End %v is before Incident.Start %v", *i.End, i.Start))
+	}
+
+	return result
+}
+
+// ValidateMaintenanceWindow implements model.Validator.ValidateMaintenanceWindow
+func (val DefaultValidator) ValidateMaintenanceWindow(w *MaintenanceWindow, mode ValidationMode) []error {
+	result := make([]error, 0)
+
+	result = checkEmpty(mode == CREATE && val.externalIDs, w.Id, "MaintenanceWindow.Id", result)
+	if (w.AgreementId == "") == (w.ProviderId == "") {
+		result = append(result, fmt.Errorf("One of MaintenanceWindow.AgreementId and MaintenanceWindow.ProviderId must be set"))
+	}
+	if w.Start.IsZero() {
+		result = append(result, fmt.Errorf("%v is not a valid date", w.Start))
+	}
+	if !w.End.After(w.Start) {
+		result = append(result, fmt.Errorf("MaintenanceWindow.End %v is not after MaintenanceWindow.Start %v", w.End, w.Start))
+	}
+	if err := w.Recurrence.Check(); err != nil {
+		result = append(result, err)
+	} else if next, _ := w.Recurrence.Next(w.Start); w.Recurrence != "" && next.Before(w.End) {
+		result = append(result, fmt.Errorf("MaintenanceWindow.Recurrence '%s' is shorter than the window", w.Recurrence))
+	}
+	if w.Until != nil && w.Recurrence == "" {
+		result = append(result, fmt.Errorf("MaintenanceWindow.Until is only valid in recurring windows"))
+	}
+	if w.Action != "" && !w.Action.IsValid() {
+		result = append(result, fmt.Errorf("MaintenanceWindow.Action '%s' is not valid", w.Action))
+	}
+
+	return result
+}
+
 // ValidateGuarantee implements model.Validator.ValidateGuarantee
 func (val DefaultValidator) ValidateGuarantee(g *Guarantee, mode ValidationMode) []error {
 	result := make([]error, 0)
 	result = checkNotEmpty(g.Name, "Guarantee.Name", result)
 	result = checkNotEmpty(g.Constraint, fmt.Sprintf("Guarantee['%s'].Constraint", g.Name), result)
+	for _, expression := range []string{g.Constraint, g.Warning} {
+		if err := CheckExpressionFunctions(expression); err != nil {
+			result = append(result, err)
+		} else if err := CheckBooleanExpression(expression); err != nil {
+			result = append(result, err)
+		}
+	}
+	if err := g.Schedule.Check(); err != nil {
+		result = append(result, err)
+	}
+	members := make(map[string]bool)
+	for _, member := range g.Scope {
+		if member == "" {
+			result = append(result, fmt.Errorf("Guarantee['%s'].Scope has empty members", g.Name))
+		} else if members[member] {
+			result = append(result, fmt.Errorf("Guarantee['%s'].Scope member '%s' is repeated", g.Name, member))
+		}
+		members[member] = true
+	}
+	if g.Tolerance != nil && (g.Tolerance.Points < 0 || g.Tolerance.Duration < 0) {
+		result = append(result, fmt.Errorf("Guarantee['%s'].Tolerance has negative values", g.Name))
+	}
+	if o := g.Objective; o != nil {
+		if o.Target <= 0 || o.Target > 1 {
+			result = append(result, fmt.Errorf("Guarantee['%s'].Objective.Target must be in (0, 1]", g.Name))
+		}
+		if err := o.Period.Check(); err != nil {
+			result = append(result, err)
+		}
+		if o.Calendar != nil {
+			if o.Period != "" {
+				result = append(result, fmt.Errorf("Guarantee['%s'].Objective cannot set both Period and Calendar", g.Name))
+			}
+			if err := o.Calendar.Check(); err != nil {
+				result = append(result, err)
+			}
+		}
+	}
+	if g.OnNoData != "" && !g.OnNoData.IsValid() {
+		result = append(result, fmt.Errorf("Guarantee['%s'].OnNoData '%s' is not valid", g.Name, g.OnNoData))
+	}
+	if g.Staleness < 0 {
+		result = append(result, fmt.Errorf("Guarantee['%s'].Staleness is negative", g.Name))
+	}
 
 	return result
 }
 
+// ValidateVariable implements model.Validator.ValidateVariable
+func (val DefaultValidator) ValidateVariable(v *Variable, mode ValidationMode) []error {
+	result := make([]error, 0)
+	result = checkNotEmpty(v.Name, "Variable.Name", result)
+	result = checkAggregation(v.Aggregation, fmt.Sprintf("Variable['%s'].Aggregation", v.Name), result)
+	result = checkMetricType(v.Type, fmt.Sprintf("Variable['%s'].Type", v.Name), result)
+	result = checkHistogramAggregation(v.Type, v.Aggregation, fmt.Sprintf("Variable['%s']", v.Name), result)
+	if r := v.Ratio; r != nil {
+		if v.Metric != "" {
+			result = append(result, fmt.Errorf("Variable['%s'] cannot set both Metric and Ratio", v.Name))
+		}
+		if v.Aggregation != nil {
+			result = append(result, fmt.Errorf("Variable['%s'] cannot set both Aggregation and Ratio", v.Name))
+		}
+		if v.Type != "" {
+			result = append(result, fmt.Errorf("Variable['%s'] cannot set both Type and Ratio", v.Name))
+		}
+		result = checkNotEmpty(r.Good.Metric, fmt.Sprintf("Variable['%s'].Ratio.Good.Metric", v.Name), result)
+		result = checkNotEmpty(r.Total.Metric, fmt.Sprintf("Variable['%s'].Ratio.Total.Metric", v.Name), result)
+		result = checkAggregation(r.Good.Aggregation, fmt.Sprintf("Variable['%s'].Ratio.Good.Aggregation", v.Name), result)
+		result = checkAggregation(r.Total.Aggregation, fmt.Sprintf("Variable['%s'].Ratio.Total.Aggregation", v.Name), result)
+		result = checkMetricType(r.Good.Type, fmt.Sprintf("Variable['%s'].Ratio.Good.Type", v.Name), result)
+		result = checkMetricType(r.Total.Type, fmt.Sprintf("Variable['%s'].Ratio.Total.Type", v.Name), result)
+		result = checkHistogramAggregation(r.Good.Type, r.Good.Aggregation, fmt.Sprintf("Variable['%s'].Ratio.Good", v.Name), result)
+		result = checkHistogramAggregation(r.Total.Type, r.Total.Aggregation, fmt.Sprintf("Variable['%s'].Ratio.Total", v.Name), result)
+		if r.Scale < 0 {
+			result = append(result, fmt.Errorf("Variable['%s'].Ratio.Scale is negative", v.Name))
+		}
+	}
+	result = checkInterpolation(v.Interpolation, fmt.Sprintf("Variable['%s'].Interpolation", v.Name), result)
+	return result
+}
+
+func checkMetricType(t MetricType, description string, current []error) []error {
+	if t != "" && !t.IsValid() {
+		current = append(current, fmt.Errorf("%s '%s' is not valid", description, t))
+	}
+	return current
+}
+
+// checkHistogramAggregation checks that a histogram metric is aggregated into a
+// number, i.e., by a median, a percentile or a count
+func checkHistogramAggregation(t MetricType, agg *Aggregation, description string, current []error) []error {
+	if !t.IsHistogram() {
+		return current
+	}
+	if agg != nil {
+		if _, ok := agg.Type.Percentile(); ok || agg.Type == MEDIAN || agg.Type == COUNT {
+			return current
+		}
+	}
+	return append(current, fmt.Errorf("%s of type %s must be aggregated by median, percentile or count", description, t))
+}
+
+func checkAggregation(agg *Aggregation, description string, current []error) []error {
+	if agg == nil {
+		return current
+	}
+	if agg.Type != "" && !agg.Type.IsValid() {
+		current = append(current, fmt.Errorf("%s.Type '%s' is not valid", description, agg.Type))
+	}
+	if agg.Window < 0 {
+		current = append(current, fmt.Errorf("%s.Window is negative", description))
+	}
+	if agg.Calendar != nil {
+		if agg.Window != 0 {
+			current = append(current, fmt.Errorf("%s cannot set both Window and Calendar", description))
+		}
+		if err := agg.Calendar.Check(); err != nil {
+			current = append(current, err)
+		}
+	}
+	return current
+}
+
+func checkInterpolation(i *Interpolation, description string, current []error) []error {
+	if i == nil {
+		return current
+	}
+	if i.Type != "" && !i.Type.IsValid() {
+		current = append(current, fmt.Errorf("%s.Type '%s' is not valid", description, i.Type))
+	}
+	if i.GetDelta() < 0 {
+		current = append(current, fmt.Errorf("%s.Delta is negative", description))
+	}
+	return current
+}
+
 func checkNotEmpty(field string, description string, current []error) []error {
 	if field == "" {
 		current = append(current, fmt.Errorf("%s is empty", description))
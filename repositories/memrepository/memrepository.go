@@ -22,17 +22,22 @@ package memrepository
 
 import (
 	"SLALite/model"
+	"sort"
+	"sync"
 
 	"github.com/spf13/viper"
 )
 
-// MemRepository is a repository in memory
+// MemRepository is a repository in memory. It is safe for concurrent use.
 type MemRepository struct {
+	mu         *sync.RWMutex
 	providers  map[string]model.Provider
 	agreements map[string]model.Agreement
 	violations map[string]model.Violation
 	penalties  map[string]model.Penalty
+	incidents  map[string]model.Incident
 	templates  map[string]model.Template
+	windows    map[string]model.MaintenanceWindow
 }
 
 // NewMemRepository creates a MemRepository with an initial state set by the parameters
@@ -57,11 +62,14 @@ func NewMemRepository(providers map[string]model.Provider, agreements map[string
 		templates = make(map[string]model.Template)
 	}
 	r = MemRepository{
+		mu:         &sync.RWMutex{},
 		providers:  providers,
 		agreements: agreements,
 		violations: violations,
 		penalties:  penalties,
+		incidents:  make(map[string]model.Incident),
 		templates:  templates,
+		windows:    make(map[string]model.MaintenanceWindow),
 	}
 	return r
 }
@@ -78,6 +86,8 @@ The list is empty when there are no providers;
 error != nil on error
 */
 func (r MemRepository) GetAllProviders() (model.Providers, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	result := make(model.Providers, 0, len(r.providers))
 
 	for _, value := range r.providers {
@@ -93,6 +103,8 @@ error != nil on error;
 error is sql.ErrNoRows if the provider is not found
 */
 func (r MemRepository) GetProvider(id string) (*model.Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var err error
 
 	item, ok := r.providers[id]
@@ -112,6 +124,8 @@ error != nil on error;
 error is sql.ErrNoRows if the provider already exists
 */
 func (r MemRepository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := provider.Id
@@ -133,6 +147,8 @@ error != nil on error;
 error is sql.ErrNoRows if the provider does not exist.
 */
 func (r MemRepository) DeleteProvider(provider *model.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := provider.Id
@@ -154,6 +170,8 @@ The list is empty when there are no agreements;
 error != nil on error
 */
 func (r MemRepository) GetAllAgreements() (model.Agreements, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	result := make(model.Agreements, 0, len(r.agreements))
 
 	for _, value := range r.agreements {
@@ -168,6 +186,8 @@ GetAgreementsByState returns the agreements that match any of the items in state
 error != nil on error
 */
 func (r MemRepository) GetAgreementsByState(states ...model.State) (model.Agreements, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	result := make(model.Agreements, 0)
 
 	for _, a := range r.agreements {
@@ -187,6 +207,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement is not found
 */
 func (r MemRepository) GetAgreement(id string) (*model.Agreement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var err error
 
 	item, ok := r.agreements[id]
@@ -206,6 +228,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement already exists
 */
 func (r MemRepository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := agreement.Id
@@ -223,6 +247,8 @@ func (r MemRepository) CreateAgreement(agreement *model.Agreement) (*model.Agree
 UpdateAgreement updates the information of an already saved instance of an agreement
 */
 func (r MemRepository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := agreement.Id
@@ -243,6 +269,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement does not exist.
 */
 func (r MemRepository) DeleteAgreement(agreement *model.Agreement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := agreement.Id
@@ -263,6 +291,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation already exists
 */
 func (r MemRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := v.Id
@@ -282,6 +312,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation is not found
 */
 func (r MemRepository) GetViolation(id string) (*model.Violation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var err error
 
 	item, ok := r.violations[id]
@@ -294,11 +326,320 @@ func (r MemRepository) GetViolation(id string) (*model.Violation, error) {
 	return &item, err
 }
 
+/*
+GetViolations returns the violations that match the filters in q,
+sorted by Datetime.
+
+The list is empty when no violation matches the query;
+error != nil on error
+*/
+func (r MemRepository) GetViolations(q model.ViolationQuery) (model.Violations, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(model.Violations, 0)
+
+	for _, v := range r.violations {
+		if r.matchViolation(&q, &v) {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Datetime.Before(result[j].Datetime)
+	})
+	return result, nil
+}
+
+func (r MemRepository) matchViolation(q *model.ViolationQuery, v *model.Violation) bool {
+	if q.AgreementId != "" && q.AgreementId != v.AgreementId {
+		return false
+	}
+	if q.Guarantee != "" && q.Guarantee != v.Guarantee {
+		return false
+	}
+	if !q.MatchTime(v.Datetime) {
+		return false
+	}
+	if q.ProviderId != "" || q.ClientId != "" {
+		a, ok := r.agreements[v.AgreementId]
+		if !ok {
+			return false
+		}
+		if q.ProviderId != "" && q.ProviderId != a.Details.Provider.Id {
+			return false
+		}
+		if q.ClientId != "" && q.ClientId != a.Details.Client.Id {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+CreatePenalty stores a new Penalty.
+
+error != nil on error;
+error is sql.ErrNoRows if the Penalty already exists
+*/
+func (r MemRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+
+	id := p.Id
+
+	if _, ok := r.penalties[id]; ok {
+		err = model.ErrAlreadyExist
+	} else {
+		r.penalties[id] = *p
+	}
+	return p, err
+}
+
+/*
+GetPenalties returns the penalties that match the filters in q,
+sorted by Datetime.
+
+The list is empty when no penalty matches the query;
+error != nil on error
+*/
+func (r MemRepository) GetPenalties(q model.PenaltyQuery) (model.Penalties, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(model.Penalties, 0)
+
+	for _, p := range r.penalties {
+		if matchPenalty(&q, &p) {
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Datetime.Before(result[j].Datetime)
+	})
+	return result, nil
+}
+
+func matchPenalty(q *model.PenaltyQuery, p *model.Penalty) bool {
+	if q.AgreementId != "" && q.AgreementId != p.AgreementId {
+		return false
+	}
+	if q.Guarantee != "" && q.Guarantee != p.Guarantee {
+		return false
+	}
+	if q.ViolationId != "" && q.ViolationId != p.ViolationId {
+		return false
+	}
+	return q.MatchTime(p.Datetime)
+}
+
+/*
+CreateIncident stores a new Incident.
+
+error != nil on error;
+error is sql.ErrNoRows if the Incident already exists
+*/
+func (r MemRepository) CreateIncident(i *model.Incident) (*model.Incident, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+
+	id := i.Id
+
+	if _, ok := r.incidents[id]; ok {
+		err = model.ErrAlreadyExist
+	} else {
+		r.incidents[id] = *i
+	}
+	return i, err
+}
+
+/*
+UpdateIncident updates the information of an already saved instance of an incident
+*/
+func (r MemRepository) UpdateIncident(i *model.Incident) (*model.Incident, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+
+	id := i.Id
+	_, ok := r.incidents[id]
+
+	if !ok {
+		err = model.ErrNotFound
+	} else {
+		r.incidents[id] = *i
+	}
+	return i, err
+}
+
+/*
+GetIncident returns the Incident identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Incident is not found
+*/
+func (r MemRepository) GetIncident(id string) (*model.Incident, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var err error
+
+	item, ok := r.incidents[id]
+
+	if !ok {
+		err = model.ErrNotFound
+	}
+	return &item, err
+}
+
+/*
+GetIncidents returns the incidents that match the filters in q,
+sorted by Start.
+
+The list is empty when no incident matches the query;
+error != nil on error
+*/
+func (r MemRepository) GetIncidents(q model.IncidentQuery) (model.Incidents, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(model.Incidents, 0)
+
+	for _, i := range r.incidents {
+		if matchIncident(&q, &i) {
+			result = append(result, i)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
+
+func matchIncident(q *model.IncidentQuery, i *model.Incident) bool {
+	if q.AgreementId != "" && q.AgreementId != i.AgreementId {
+		return false
+	}
+	if q.Guarantee != "" && q.Guarantee != i.Guarantee {
+		return false
+	}
+	if q.OnlyOpen && !i.IsOpen() {
+		return false
+	}
+	return q.MatchTime(i.Start)
+}
+
+/*
+CreateMaintenanceWindow stores a new MaintenanceWindow.
+
+error != nil on error;
+error is sql.ErrNoRows if the MaintenanceWindow already exists
+*/
+func (r MemRepository) CreateMaintenanceWindow(w *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+
+	id := w.Id
+
+	if _, ok := r.windows[id]; ok {
+		err = model.ErrAlreadyExist
+	} else {
+		r.windows[id] = *w
+	}
+	return w, err
+}
+
+/*
+UpdateMaintenanceWindow updates the information of an already saved instance of a
+maintenance window
+*/
+func (r MemRepository) UpdateMaintenanceWindow(w *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+
+	id := w.Id
+	_, ok := r.windows[id]
+
+	if !ok {
+		err = model.ErrNotFound
+	} else {
+		r.windows[id] = *w
+	}
+	return w, err
+}
+
+/*
+GetMaintenanceWindow returns the MaintenanceWindow identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the MaintenanceWindow is not found
+*/
+func (r MemRepository) GetMaintenanceWindow(id string) (*model.MaintenanceWindow, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var err error
+
+	item, ok := r.windows[id]
+
+	if !ok {
+		err = model.ErrNotFound
+	}
+	return &item, err
+}
+
+/*
+GetMaintenanceWindows returns the maintenance windows that match the filters in q,
+sorted by Start.
+
+The list is empty when no window matches the query;
+error != nil on error
+*/
+func (r MemRepository) GetMaintenanceWindows(q model.MaintenanceWindowQuery) (model.MaintenanceWindows, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(model.MaintenanceWindows, 0)
+
+	for _, w := range r.windows {
+		if (q.AgreementId == "" || q.AgreementId == w.AgreementId) &&
+			(q.ProviderId == "" || q.ProviderId == w.ProviderId) {
+			result = append(result, w)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
+
+/*
+DeleteMaintenanceWindow deletes from the repository the MaintenanceWindow whose id is w.Id.
+
+error != nil on error;
+error is sql.ErrNoRows if the MaintenanceWindow does not exist.
+*/
+func (r MemRepository) DeleteMaintenanceWindow(w *model.MaintenanceWindow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+
+	id := w.Id
+
+	_, ok := r.windows[id]
+
+	if ok {
+		delete(r.windows, id)
+	} else {
+		err = model.ErrNotFound
+	}
+	return err
+}
+
 /*
 UpdateAgreementState transits the state of the agreement
 */
 func (r MemRepository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var ok bool
 	var err error
 	var current model.Agreement
@@ -324,6 +665,8 @@ error != nil on error
 */
 func (r MemRepository) GetAllTemplates() (model.Templates, error) {
 
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	result := make(model.Templates, 0, len(r.templates))
 
 	for _, value := range r.templates {
@@ -339,6 +682,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Template is not found
 */
 func (r MemRepository) GetTemplate(id string) (*model.Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var err error
 
 	item, ok := r.templates[id]
@@ -358,6 +703,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Template already exists
 */
 func (r MemRepository) CreateTemplate(template *model.Template) (*model.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	var err error
 
 	id := template.Id
@@ -22,6 +22,7 @@ package mongodb
 import (
 	"SLALite/model"
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -37,6 +38,10 @@ const (
 	repositoryDbName        string = "slalite"
 	providersCollectionName string = "Providers"
 	agreementCollectionName string = "Agreements"
+	violationCollectionName string = "Violations"
+	penaltyCollectionName   string = "Penalties"
+	incidentCollectionName  string = "Incidents"
+	windowCollectionName    string = "MaintenanceWindows"
 
 	mongoConfigName string = "mongodb.yml"
 
@@ -275,7 +280,8 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation already exists
 */
 func (r MongoDBRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
-	return nil, fmt.Errorf("Not implemented")
+	res, err := r.create(violationCollectionName, v)
+	return res.(*model.Violation), err
 }
 
 /*
@@ -285,7 +291,232 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation is not found
 */
 func (r MongoDBRepository) GetViolation(id string) (*model.Violation, error) {
-	return nil, fmt.Errorf("Not implemented")
+	res, err := r.get(violationCollectionName, id, new(model.Violation))
+	return res.(*model.Violation), err
+}
+
+/*
+GetViolations returns the violations that match the filters in q,
+sorted by Datetime.
+
+The list is empty when no violation matches the query;
+error != nil on error
+*/
+func (r MongoDBRepository) GetViolations(q model.ViolationQuery) (model.Violations, error) {
+	result := make(model.Violations, 0)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.ProviderId != "" || q.ClientId != "" {
+		ids, err := r.getAgreementIdsByParties(q.ProviderId, q.ClientId)
+		if err != nil {
+			return result, err
+		}
+		if q.AgreementId != "" {
+			query["agreementid"] = bson.M{"$eq": q.AgreementId, "$in": ids}
+		} else {
+			query["agreementid"] = bson.M{"$in": ids}
+		}
+	}
+	if q.Guarantee != "" {
+		query["guarantee"] = q.Guarantee
+	}
+	addTimeInterval(query, "datetime", q.From, q.To)
+	err := r.database.C(violationCollectionName).Find(query).Sort("datetime").All(&result)
+	return result, err
+}
+
+// getAgreementIdsByParties returns the ids of the agreements whose provider and client
+// match the parameters. An empty parameter is not taken into account.
+func (r MongoDBRepository) getAgreementIdsByParties(providerID, clientID string) ([]string, error) {
+	query := bson.M{}
+	if providerID != "" {
+		query["details.provider._id"] = providerID
+	}
+	if clientID != "" {
+		query["details.client._id"] = clientID
+	}
+	var agreements []struct {
+		Id string `bson:"_id"`
+	}
+	err := r.database.C(agreementCollectionName).Find(query).Select(bson.M{"_id": 1}).All(&agreements)
+
+	ids := make([]string, 0, len(agreements))
+	for _, a := range agreements {
+		ids = append(ids, a.Id)
+	}
+	return ids, err
+}
+
+// addTimeInterval adds to query a [from, to) filter on field.
+// A zero from or to is not taken into account.
+func addTimeInterval(query bson.M, field string, from, to time.Time) {
+	if from.IsZero() && to.IsZero() {
+		return
+	}
+	interval := bson.M{}
+	if !from.IsZero() {
+		interval["$gte"] = from
+	}
+	if !to.IsZero() {
+		interval["$lt"] = to
+	}
+	query[field] = interval
+}
+
+/*
+CreatePenalty stores a new Penalty.
+
+error != nil on error;
+error is sql.ErrNoRows if the Penalty already exists
+*/
+func (r MongoDBRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	res, err := r.create(penaltyCollectionName, p)
+	return res.(*model.Penalty), err
+}
+
+/*
+GetPenalties returns the penalties that match the filters in q,
+sorted by Datetime.
+
+The list is empty when no penalty matches the query;
+error != nil on error
+*/
+func (r MongoDBRepository) GetPenalties(q model.PenaltyQuery) (model.Penalties, error) {
+	result := make(model.Penalties, 0)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.Guarantee != "" {
+		query["guarantee"] = q.Guarantee
+	}
+	if q.ViolationId != "" {
+		query["violationid"] = q.ViolationId
+	}
+	addTimeInterval(query, "datetime", q.From, q.To)
+	err := r.database.C(penaltyCollectionName).Find(query).Sort("datetime").All(&result)
+	return result, err
+}
+
+/*
+CreateIncident stores a new Incident.
+
+error != nil on error;
+error is sql.ErrNoRows if the Incident already exists
+*/
+func (r MongoDBRepository) CreateIncident(i *model.Incident) (*model.Incident, error) {
+	res, err := r.create(incidentCollectionName, i)
+	return res.(*model.Incident), err
+}
+
+/*
+UpdateIncident updates the information of an already saved instance of an incident
+*/
+func (r MongoDBRepository) UpdateIncident(i *model.Incident) (*model.Incident, error) {
+	err := r.update(incidentCollectionName, i.Id, i)
+	return i, err
+}
+
+/*
+GetIncident returns the Incident identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Incident is not found
+*/
+func (r MongoDBRepository) GetIncident(id string) (*model.Incident, error) {
+	res, err := r.get(incidentCollectionName, id, new(model.Incident))
+	return res.(*model.Incident), err
+}
+
+/*
+GetIncidents returns the incidents that match the filters in q,
+sorted by Start.
+
+The list is empty when no incident matches the query;
+error != nil on error
+*/
+func (r MongoDBRepository) GetIncidents(q model.IncidentQuery) (model.Incidents, error) {
+	result := make(model.Incidents, 0)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.Guarantee != "" {
+		query["guarantee"] = q.Guarantee
+	}
+	if q.OnlyOpen {
+		query["end"] = nil
+	}
+	addTimeInterval(query, "start", q.From, q.To)
+	err := r.database.C(incidentCollectionName).Find(query).Sort("start").All(&result)
+	return result, err
+}
+
+/*
+CreateMaintenanceWindow stores a new MaintenanceWindow.
+
+error != nil on error;
+error is sql.ErrNoRows if the MaintenanceWindow already exists
+*/
+func (r MongoDBRepository) CreateMaintenanceWindow(w *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	res, err := r.create(windowCollectionName, w)
+	return res.(*model.MaintenanceWindow), err
+}
+
+/*
+UpdateMaintenanceWindow updates the information of an already saved instance of a
+maintenance window
+*/
+func (r MongoDBRepository) UpdateMaintenanceWindow(w *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+	err := r.update(windowCollectionName, w.Id, w)
+	return w, err
+}
+
+/*
+GetMaintenanceWindow returns the MaintenanceWindow identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the MaintenanceWindow is not found
+*/
+func (r MongoDBRepository) GetMaintenanceWindow(id string) (*model.MaintenanceWindow, error) {
+	res, err := r.get(windowCollectionName, id, new(model.MaintenanceWindow))
+	return res.(*model.MaintenanceWindow), err
+}
+
+/*
+GetMaintenanceWindows returns the maintenance windows that match the filters in q,
+sorted by Start.
+
+The list is empty when no window matches the query;
+error != nil on error
+*/
+func (r MongoDBRepository) GetMaintenanceWindows(q model.MaintenanceWindowQuery) (model.MaintenanceWindows, error) {
+	result := make(model.MaintenanceWindows, 0)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.ProviderId != "" {
+		query["providerid"] = q.ProviderId
+	}
+	err := r.database.C(windowCollectionName).Find(query).Sort("start").All(&result)
+	return result, err
+}
+
+/*
+DeleteMaintenanceWindow deletes from the repository the MaintenanceWindow whose id is w.Id.
+
+error != nil on error;
+error is sql.ErrNoRows if the MaintenanceWindow does not exist.
+*/
+func (r MongoDBRepository) DeleteMaintenanceWindow(w *model.MaintenanceWindow) error {
+	return r.delete(windowCollectionName, w.Id)
 }
 
 /*
@@ -92,11 +92,30 @@ func TestRepository(t *testing.T) {
 	t.Run("DeleteAgreementNotExists", ctx.TestDeleteAgreementNotExists)
 
 	/* Violations */
-	// t.Run("CreateViolation", ctx.TestCreateViolation)
-	// t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
-
-	// t.Run("GetViolation", ctx.TestGetViolation)
-	// t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("CreateViolation", ctx.TestCreateViolation)
+	t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
+
+	t.Run("GetViolation", ctx.TestGetViolation)
+	t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("GetViolations", ctx.TestGetViolations)
+
+	/* Penalties */
+	t.Run("CreatePenalty", ctx.TestCreatePenalty)
+	t.Run("CreatePenaltyExists", ctx.TestCreatePenaltyExists)
+	t.Run("GetPenalties", ctx.TestGetPenalties)
+
+	/* Incidents */
+	t.Run("CreateIncident", ctx.TestCreateIncident)
+	t.Run("CreateIncidentExists", ctx.TestCreateIncidentExists)
+	t.Run("GetIncidents", ctx.TestGetIncidents)
+	t.Run("UpdateIncident", ctx.TestUpdateIncident)
+
+	/* Maintenance windows */
+	t.Run("CreateMaintenanceWindow", ctx.TestCreateMaintenanceWindow)
+	t.Run("CreateMaintenanceWindowExists", ctx.TestCreateMaintenanceWindowExists)
+	t.Run("GetMaintenanceWindows", ctx.TestGetMaintenanceWindows)
+	t.Run("UpdateMaintenanceWindow", ctx.TestUpdateMaintenanceWindow)
+	t.Run("DeleteMaintenanceWindow", ctx.TestDeleteMaintenanceWindow)
 
 	/* Templates */
 	// t.Run("CreateTemplate", ctx.TestCreateTemplate)
@@ -36,6 +36,9 @@ type TestData struct {
 	Anotexists model.Agreement
 	V01        model.Violation
 	Vnotexists model.Violation
+	Pe01       model.Penalty
+	I01        model.Incident
+	W01        model.MaintenanceWindow
 	T01        model.Template
 }
 
@@ -91,6 +94,27 @@ var Data = TestData{
 		Id:          "vnotexists",
 		AgreementId: "a01",
 	},
+	Pe01: model.Penalty{
+		Id:          "pe01",
+		AgreementId: "a01",
+		Guarantee:   "gt1",
+		ViolationId: "v01",
+		Datetime:    time.Now(),
+		Definition:  model.PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+	},
+	I01: model.Incident{
+		Id:          "i01",
+		AgreementId: "a01",
+		Guarantee:   "gt1",
+		Start:       time.Now(),
+		Violations:  []string{"v01"},
+	},
+	W01: model.MaintenanceWindow{
+		Id:          "w01",
+		AgreementId: "a01",
+		Start:       time.Now(),
+		End:         time.Now().Add(time.Hour),
+	},
 	T01: model.Template{
 		Id:   "t01",
 		Name: "Template01",
@@ -336,6 +360,188 @@ func (r *TestContext) TestGetViolationNotExists(t *testing.T) {
 	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
 }
 
+// TestGetViolations executes this test
+func (r *TestContext) TestGetViolations(t *testing.T) {
+	actual, err := r.Repo.GetViolations(model.ViolationQuery{AgreementId: Data.V01.AgreementId})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, err = r.Repo.GetViolations(model.ViolationQuery{
+		AgreementId: Data.V01.AgreementId,
+		Guarantee:   Data.V01.Guarantee,
+		From:        Data.V01.Datetime.Add(-time.Minute),
+		To:          Data.V01.Datetime.Add(time.Minute),
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, err = r.Repo.GetViolations(model.ViolationQuery{Guarantee: "notexists"})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 0, len(actual))
+
+	actual, err = r.Repo.GetViolations(model.ViolationQuery{From: Data.V01.Datetime.Add(time.Minute)})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 0, len(actual))
+
+	actual, err = r.Repo.GetViolations(model.ViolationQuery{ProviderId: Data.Pnotexists.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 0, len(actual))
+}
+
+// TestCreatePenalty executes this test
+func (r *TestContext) TestCreatePenalty(t *testing.T) {
+	// When on externalId repo, we have to sync p.AgreementId and p.ViolationId
+	Data.Pe01.AgreementId = Data.A01.Id
+	Data.Pe01.ViolationId = Data.V01.Id
+	p, err := r.Repo.CreatePenalty(&Data.Pe01)
+	Data.Pe01 = *p
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+}
+
+// TestCreatePenaltyExists executes this test
+func (r *TestContext) TestCreatePenaltyExists(t *testing.T) {
+	_, err := r.Repo.CreatePenalty(&Data.Pe01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrAlreadyExist, err)
+}
+
+// TestGetPenalties executes this test
+func (r *TestContext) TestGetPenalties(t *testing.T) {
+	actual, err := r.Repo.GetPenalties(model.PenaltyQuery{AgreementId: Data.Pe01.AgreementId})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected penalty. Expected: %v; Actual: %v", Data.Pe01.Id, actual[0].Id)
+
+	actual, err = r.Repo.GetPenalties(model.PenaltyQuery{
+		AgreementId: Data.Pe01.AgreementId,
+		Guarantee:   Data.Pe01.Guarantee,
+		ViolationId: Data.Pe01.ViolationId,
+		From:        Data.Pe01.Datetime.Add(-time.Minute),
+		To:          Data.Pe01.Datetime.Add(time.Minute),
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, err = r.Repo.GetPenalties(model.PenaltyQuery{ViolationId: Data.Vnotexists.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 0, len(actual))
+
+	actual, err = r.Repo.GetPenalties(model.PenaltyQuery{To: Data.Pe01.Datetime.Add(-time.Minute)})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 0, len(actual))
+}
+
+// TestCreateIncident executes this test
+func (r *TestContext) TestCreateIncident(t *testing.T) {
+	// When on externalId repo, we have to sync i.AgreementId and i.Violations
+	Data.I01.AgreementId = Data.A01.Id
+	Data.I01.Violations = []string{Data.V01.Id}
+	i, err := r.Repo.CreateIncident(&Data.I01)
+	Data.I01 = *i
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+}
+
+// TestCreateIncidentExists executes this test
+func (r *TestContext) TestCreateIncidentExists(t *testing.T) {
+	_, err := r.Repo.CreateIncident(&Data.I01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrAlreadyExist, err)
+}
+
+// TestGetIncidents executes this test
+func (r *TestContext) TestGetIncidents(t *testing.T) {
+	actual, err := r.Repo.GetIncidents(model.IncidentQuery{AgreementId: Data.I01.AgreementId})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(incidents). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected incident. Expected: %v; Actual: %v", Data.I01.Id, actual[0].Id)
+
+	actual, err = r.Repo.GetIncidents(model.IncidentQuery{
+		AgreementId: Data.I01.AgreementId,
+		Guarantee:   Data.I01.Guarantee,
+		OnlyOpen:    true,
+		From:        Data.I01.Start.Add(-time.Minute),
+		To:          Data.I01.Start.Add(time.Minute),
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(incidents). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, err = r.Repo.GetIncidents(model.IncidentQuery{To: Data.I01.Start.Add(-time.Minute)})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(incidents). Expected: %d; Actual: %d", 0, len(actual))
+}
+
+// TestUpdateIncident executes this test
+func (r *TestContext) TestUpdateIncident(t *testing.T) {
+	Data.I01.Close(Data.I01.Start.Add(time.Minute))
+	_, err := r.Repo.UpdateIncident(&Data.I01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	i, err := r.Repo.GetIncident(Data.I01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected IsOpen(). Expected: %v; Actual: %v", false, i.IsOpen())
+	assertEquals(t, "Unexpected Duration. Expected: %v; Actual: %v", 60.0, i.Duration)
+
+	actual, err := r.Repo.GetIncidents(model.IncidentQuery{AgreementId: Data.I01.AgreementId, OnlyOpen: true})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(incidents). Expected: %d; Actual: %d", 0, len(actual))
+
+	i = &model.Incident{Id: "notexists"}
+	_, err = r.Repo.UpdateIncident(i)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestCreateMaintenanceWindow executes this test
+func (r *TestContext) TestCreateMaintenanceWindow(t *testing.T) {
+	// When on externalId repo, we have to sync w.AgreementId
+	Data.W01.AgreementId = Data.A01.Id
+	w, err := r.Repo.CreateMaintenanceWindow(&Data.W01)
+	Data.W01 = *w
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+}
+
+// TestCreateMaintenanceWindowExists executes this test
+func (r *TestContext) TestCreateMaintenanceWindowExists(t *testing.T) {
+	_, err := r.Repo.CreateMaintenanceWindow(&Data.W01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrAlreadyExist, err)
+}
+
+// TestGetMaintenanceWindows executes this test
+func (r *TestContext) TestGetMaintenanceWindows(t *testing.T) {
+	actual, err := r.Repo.GetMaintenanceWindows(model.MaintenanceWindowQuery{AgreementId: Data.W01.AgreementId})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(windows). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected window. Expected: %v; Actual: %v", Data.W01.Id, actual[0].Id)
+
+	actual, err = r.Repo.GetMaintenanceWindows(model.MaintenanceWindowQuery{ProviderId: Data.P01.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(windows). Expected: %d; Actual: %d", 0, len(actual))
+}
+
+// TestUpdateMaintenanceWindow executes this test
+func (r *TestContext) TestUpdateMaintenanceWindow(t *testing.T) {
+	Data.W01.Recurrence = "P1D"
+	_, err := r.Repo.UpdateMaintenanceWindow(&Data.W01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	w, err := r.Repo.GetMaintenanceWindow(Data.W01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected Recurrence. Expected: %v; Actual: %v", Data.W01.Recurrence, w.Recurrence)
+
+	w = &model.MaintenanceWindow{Id: "notexists"}
+	_, err = r.Repo.UpdateMaintenanceWindow(w)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestDeleteMaintenanceWindow executes this test
+func (r *TestContext) TestDeleteMaintenanceWindow(t *testing.T) {
+	err := r.Repo.DeleteMaintenanceWindow(&Data.W01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	_, err = r.Repo.GetMaintenanceWindow(Data.W01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+
+	err = r.Repo.DeleteMaintenanceWindow(&Data.W01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
 // TestCreateTemplate executes this test
 func (r *TestContext) TestCreateTemplate(t *testing.T) {
 	var tpl *model.Template
@@ -154,6 +154,91 @@ func (r repository) GetViolation(id string) (*model.Violation, error) {
 	return r.backend.GetViolation(id)
 }
 
+// GetViolations returns the violations that match a query.
+func (r repository) GetViolations(q model.ViolationQuery) (model.Violations, error) {
+	return r.backend.GetViolations(q)
+}
+
+// CreatePenalty validates and persists a new Penalty.
+func (r repository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+
+	if errs := p.Validate(r.val, model.CREATE); len(errs) > 0 {
+		err := newValError(errs)
+		return p, err
+	}
+	return r.backend.CreatePenalty(p)
+}
+
+// GetPenalties returns the penalties that match a query.
+func (r repository) GetPenalties(q model.PenaltyQuery) (model.Penalties, error) {
+	return r.backend.GetPenalties(q)
+}
+
+// CreateIncident validates and persists a new Incident.
+func (r repository) CreateIncident(i *model.Incident) (*model.Incident, error) {
+
+	if errs := i.Validate(r.val, model.CREATE); len(errs) > 0 {
+		err := newValError(errs)
+		return i, err
+	}
+	return r.backend.CreateIncident(i)
+}
+
+// UpdateIncident validates and updates an Incident.
+func (r repository) UpdateIncident(i *model.Incident) (*model.Incident, error) {
+
+	if errs := i.Validate(r.val, model.UPDATE); len(errs) > 0 {
+		err := newValError(errs)
+		return i, err
+	}
+	return r.backend.UpdateIncident(i)
+}
+
+// GetIncident returns the Incident identified by id.
+func (r repository) GetIncident(id string) (*model.Incident, error) {
+	return r.backend.GetIncident(id)
+}
+
+// GetIncidents returns the incidents that match a query.
+func (r repository) GetIncidents(q model.IncidentQuery) (model.Incidents, error) {
+	return r.backend.GetIncidents(q)
+}
+
+// CreateMaintenanceWindow validates and persists a new MaintenanceWindow.
+func (r repository) CreateMaintenanceWindow(w *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+
+	if errs := w.Validate(r.val, model.CREATE); len(errs) > 0 {
+		err := newValError(errs)
+		return w, err
+	}
+	return r.backend.CreateMaintenanceWindow(w)
+}
+
+// UpdateMaintenanceWindow validates and updates a MaintenanceWindow.
+func (r repository) UpdateMaintenanceWindow(w *model.MaintenanceWindow) (*model.MaintenanceWindow, error) {
+
+	if errs := w.Validate(r.val, model.UPDATE); len(errs) > 0 {
+		err := newValError(errs)
+		return w, err
+	}
+	return r.backend.UpdateMaintenanceWindow(w)
+}
+
+// GetMaintenanceWindow returns the MaintenanceWindow identified by id.
+func (r repository) GetMaintenanceWindow(id string) (*model.MaintenanceWindow, error) {
+	return r.backend.GetMaintenanceWindow(id)
+}
+
+// GetMaintenanceWindows returns the maintenance windows that match a query.
+func (r repository) GetMaintenanceWindows(q model.MaintenanceWindowQuery) (model.MaintenanceWindows, error) {
+	return r.backend.GetMaintenanceWindows(q)
+}
+
+// DeleteMaintenanceWindow deletes a MaintenanceWindow.
+func (r repository) DeleteMaintenanceWindow(w *model.MaintenanceWindow) error {
+	return r.backend.DeleteMaintenanceWindow(w)
+}
+
 // UpdateAgreement changes the state of an Agreement.
 func (r repository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
 	var err error
@@ -47,6 +47,13 @@ func TestCallThroughMethods(t *testing.T) {
 	v.GetAllAgreements()
 	v.GetAgreementsByState()
 	v.GetViolation("id")
+	v.GetViolations(model.ViolationQuery{})
+	v.GetPenalties(model.PenaltyQuery{})
+	v.GetIncident("id")
+	v.GetIncidents(model.IncidentQuery{})
+	v.GetMaintenanceWindow("id")
+	v.GetMaintenanceWindows(model.MaintenanceWindowQuery{})
+	v.DeleteMaintenanceWindow(&model.MaintenanceWindow{Id: "id"})
 	v.CreateAgreement(a)
 	v.UpdateAgreement(a)
 	v.UpdateAgreementState(a.Id, model.TERMINATED)
@@ -132,6 +139,65 @@ func TestRepositoryWithExternalIds(t *testing.T) {
 		return
 	}
 
+	pe := &model.Penalty{
+		Id:          "",
+		AgreementId: "id",
+		Guarantee:   "gt",
+		ViolationId: vi.Id,
+		Datetime:    time.Now(),
+		Definition:  model.PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+	}
+	pe, err = v.CreatePenalty(pe)
+	if err != nil {
+		t.Errorf("No errors expected. Found %v", err)
+		return
+	}
+
+	pe.Id = "id"
+	pe, err = v.CreatePenalty(pe)
+	if err == nil {
+		t.Errorf("Errors expected. Found %v", err)
+		return
+	}
+
+	in := &model.Incident{
+		Id:          "",
+		AgreementId: "id",
+		Guarantee:   "gt",
+		Start:       time.Now(),
+	}
+	in, err = v.CreateIncident(in)
+	if err != nil {
+		t.Errorf("No errors expected. Found %v", err)
+		return
+	}
+
+	in.Close(in.Start.Add(-time.Minute))
+	in, err = v.UpdateIncident(in)
+	if err == nil {
+		t.Errorf("Errors expected. Found %v", err)
+		return
+	}
+
+	mw := &model.MaintenanceWindow{
+		Id:          "",
+		AgreementId: "id",
+		Start:       time.Now(),
+		End:         time.Now().Add(time.Hour),
+	}
+	mw, err = v.CreateMaintenanceWindow(mw)
+	if err != nil {
+		t.Errorf("No errors expected. Found %v", err)
+		return
+	}
+
+	mw.End = mw.Start
+	mw, err = v.UpdateMaintenanceWindow(mw)
+	if err == nil {
+		t.Errorf("Errors expected. Found %v", err)
+		return
+	}
+
 	tpl.Id = ""
 	tpl, err = v.CreateTemplate(tpl)
 	if err != nil {
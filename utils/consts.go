@@ -31,9 +31,24 @@ const (
 	// DefaultExternalIDs is the default value of externalIDs
 	DefaultExternalIDs bool = false
 
+	// DefaultAssessmentWorkers is the default number of agreements assessed concurrently
+	DefaultAssessmentWorkers int = 1
+
+	// DefaultAssessmentTimeout is the default number of seconds the assessment of an
+	// agreement may take. Zero means no timeout.
+	DefaultAssessmentTimeout time.Duration = 0
+
 	// CheckPeriodPropertyName is the name of the property CheckPeriod
 	CheckPeriodPropertyName = "checkPeriod"
 
+	// AssessmentWorkersPropertyName is the name of the property that sets the number
+	// of agreements assessed concurrently
+	AssessmentWorkersPropertyName = "assessmentWorkers"
+
+	// AssessmentTimeoutPropertyName is the name of the property that sets the number
+	// of seconds the assessment of an agreement may take
+	AssessmentTimeoutPropertyName = "assessmentTimeout"
+
 	// RepositoryTypePropertyName is the name of the property repository type
 	RepositoryTypePropertyName = "repository"
 